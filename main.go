@@ -5,6 +5,7 @@ import (
 	"flag"
 	"log"
 	"terraform-provider-technicolor/provider"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 )
@@ -18,6 +19,10 @@ var (
 	// commit  string = ""
 )
 
+// the time left to logout from the routers, shorter than the 2 seconds
+// terraform waits before killing the plugin
+const SHUTDOWN_TIMEOUT = 1 * time.Second
+
 func main() {
 	var debug bool
 
@@ -33,6 +38,15 @@ func main() {
 
 	err := providerserver.Serve(context.Background(), provider.New(version), opts)
 
+	// terraform stops the plugin once it is done and kills it if it does not
+	// exit within 2 seconds, close the router sessions well before that
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	if shutdownErr := provider.Shutdown(ctx); shutdownErr != nil {
+		log.Println(shutdownErr.Error())
+	}
+
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"terraform-provider-technicolor/technicolor"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// addRouterError reports an error of the router client, explaining the
+// likely cause depending on the kind of error
+func addRouterError(diags *diag.Diagnostics, summary string, err error) {
+	diags.AddError(summary, routerErrorDetail(err))
+}
+
+// addRouterWriteError reports an error of a write like addRouterError, the
+// values refused by the router are reported against the attribute of their
+// form parameter in attributes
+func addRouterWriteError(diags *diag.Diagnostics, summary string, err error, attributes map[string]string) {
+	var validationError *technicolor.ValidationError
+
+	if !errors.As(err, &validationError) {
+		addRouterError(diags, summary, err)
+		return
+	}
+
+	for _, fieldError := range validationError.Errors {
+		if attribute, ok := attributes[fieldError.Field]; ok {
+			diags.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName(attribute),
+				summary,
+				fmt.Sprintf("The router refused the value: %s", fieldError.Message),
+			)
+			continue
+		}
+
+		detail := fieldError.Message
+		if fieldError.Field != "" {
+			detail = fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message)
+		}
+		diags.AddError(summary, fmt.Sprintf("The router refused the configuration: %s", detail))
+	}
+}
+
+func routerErrorDetail(err error) string {
+	var hint string
+	var unknownAuthority x509.UnknownAuthorityError
+	var statusError *technicolor.StatusError
+
+	switch {
+	case errors.Is(err, technicolor.ErrAuthenticationFailed):
+		hint = "The router refused the credentials, check the username and password."
+	case errors.Is(err, technicolor.ErrSessionExpired):
+		hint = "The router session expired, it may have been closed from the web UI or by another login."
+	case errors.Is(err, technicolor.ErrCSRFRejected):
+		hint = "The router refused the CSRF token of the request, the session may have been replaced by another login."
+	case errors.Is(err, technicolor.ErrNotFound):
+		hint = "The object does not exist on the router, it may have been removed from the web UI."
+	case errors.Is(err, technicolor.ErrConflict):
+		hint = "The router already has a matching object, import it or make the configuration more specific."
+	case errors.Is(err, technicolor.ErrUnexpectedLayout):
+		hint = "The router page does not look as expected, the firmware may not be supported. Please report it to the provider developers."
+	case errors.Is(err, technicolor.ErrValidation):
+		hint = "The router refused the configuration, nothing was changed."
+	case errors.Is(err, technicolor.ErrInvalidSRPParameters):
+		hint = "The login parameters of the router are not supported by the provider."
+	case errors.Is(err, technicolor.ErrCertificateMismatch):
+		hint = "The router certificate is not the one of certificate_fingerprint, it may have been regenerated or the connection intercepted."
+	case errors.As(err, &unknownAuthority):
+		hint = "The router certificate is not signed by a trusted CA, set ca_certificate or certificate_fingerprint, or insecure_skip_verify."
+	case errors.As(err, &statusError) && statusError.StatusCode >= 500:
+		hint = "The router failed to answer, it may be overloaded. Consider increasing retries or min_request_interval."
+	case errors.Is(err, context.DeadlineExceeded):
+		hint = "The router did not answer in time, consider increasing request_timeout or timeout."
+	default:
+		return err.Error()
+	}
+
+	return err.Error() + "\n\n" + hint
+}
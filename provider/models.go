@@ -1,17 +1,94 @@
 package provider
 
 import (
+	"terraform-provider-technicolor/technicolor"
+
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 type PortForwarded struct {
-	ID       types.String `tfsdk:"id"`
-	Index    types.Int64  `tfsdk:"index"`
-	Enabled  types.Bool   `tfsdk:"enabled"`
-	Name     types.String `tfsdk:"name"`
-	Protocol types.String `tfsdk:"protocol"`
-	WanPort  types.Int64  `tfsdk:"wan_port"`
-	LanPort  types.Int64  `tfsdk:"lan_port"`
-	LanIp    types.String `tfsdk:"lan_ip"`
-	LanMac   types.String `tfsdk:"lan_mac"`
+	ID           types.String `tfsdk:"id"`
+	Index        types.Int64  `tfsdk:"index"`
+	Enabled      types.Bool   `tfsdk:"enabled"`
+	Name         types.String `tfsdk:"name"`
+	Protocol     types.String `tfsdk:"protocol"`
+	WanPortStart types.Int64  `tfsdk:"wan_port_start"`
+	WanPortEnd   types.Int64  `tfsdk:"wan_port_end"`
+	LanPortStart types.Int64  `tfsdk:"lan_port_start"`
+	LanPortEnd   types.Int64  `tfsdk:"lan_port_end"`
+	LanIp        types.String `tfsdk:"lan_ip"`
+	LanMac       types.String `tfsdk:"lan_mac"`
+}
+
+func portForwardedToModel(port technicolor.PortForwardedWithIndex) PortForwarded {
+	return PortForwarded{
+		ID:           types.String{Value: computeID(port.Data.Name, port.Data.WanPortStart, port.Data.Protocol)},
+		Index:        types.Int64{Value: int64(port.Index)},
+		Enabled:      types.Bool{Value: port.Data.Enabled},
+		Name:         types.String{Value: port.Data.Name},
+		Protocol:     types.String{Value: port.Data.Protocol},
+		WanPortStart: types.Int64{Value: int64(port.Data.WanPortStart)},
+		WanPortEnd:   types.Int64{Value: int64(port.Data.WanPortEnd)},
+		LanPortStart: types.Int64{Value: int64(port.Data.LanPortStart)},
+		LanPortEnd:   types.Int64{Value: int64(port.Data.LanPortEnd)},
+		LanIp:        types.String{Value: port.Data.LanIp},
+		LanMac:       types.String{Value: port.Data.LanMac},
+	}
+}
+
+func portForwardedFromModel(model *PortForwarded) technicolor.PortForwarded {
+	portForwarded := technicolor.PortForwarded{
+		Enabled:      model.Enabled.Value,
+		Name:         model.Name.Value,
+		Protocol:     model.Protocol.Value,
+		WanPortStart: int(model.WanPortStart.Value),
+		WanPortEnd:   int(model.WanPortEnd.Value),
+		LanPortStart: int(model.LanPortStart.Value),
+		LanPortEnd:   int(model.LanPortEnd.Value),
+		LanIp:        model.LanIp.Value,
+		LanMac:       model.LanMac.Value,
+	}
+
+	// the end of the range is optional and defaults to a single port
+	if model.WanPortEnd.Null || model.WanPortEnd.Unknown {
+		portForwarded.WanPortEnd = portForwarded.WanPortStart
+	}
+
+	if model.LanPortEnd.Null || model.LanPortEnd.Unknown {
+		portForwarded.LanPortEnd = portForwarded.LanPortStart
+	}
+
+	return portForwarded
+}
+
+func portForwardedIdentityFromModel(model *PortForwarded) technicolor.PortForwardedIdentity {
+	return technicolor.PortForwardedIdentity{
+		Name:         model.Name.Value,
+		Protocol:     model.Protocol.Value,
+		WanPortStart: int(model.WanPortStart.Value),
+		LanMac:       model.LanMac.Value,
+	}
+}
+
+type RouterInfo struct {
+	ID              types.String `tfsdk:"id"`
+	ProductName     types.String `tfsdk:"product_name"`
+	HardwareVersion types.String `tfsdk:"hardware_version"`
+	FirmwareVersion types.String `tfsdk:"firmware_version"`
+	SerialNumber    types.String `tfsdk:"serial_number"`
+	MacAddress      types.String `tfsdk:"mac_address"`
+	Uptime          types.Int64  `tfsdk:"uptime"`
+}
+
+func routerInfoToModel(info technicolor.RouterInfo) RouterInfo {
+	return RouterInfo{
+		// the serial number is the only stable identity of the device
+		ID:              types.String{Value: info.SerialNumber},
+		ProductName:     types.String{Value: info.ProductName},
+		HardwareVersion: types.String{Value: info.HardwareVersion},
+		FirmwareVersion: types.String{Value: info.FirmwareVersion},
+		SerialNumber:    types.String{Value: info.SerialNumber},
+		MacAddress:      types.String{Value: info.MacAddress},
+		Uptime:          types.Int64{Value: int64(info.Uptime.Seconds())},
+	}
 }
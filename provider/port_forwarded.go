@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type datasourcePortForwardedType struct{}
+
+func (c datasourcePortForwardedType) GetSchema(_ context.Context) (tfsdk.Schema,
+	diag.Diagnostics) {
+	return tfsdk.Schema{
+		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Type:        types.StringType,
+				Description: "The id of the rule (name/protocol/wanport)",
+				Optional:    true,
+				Computed:    true,
+			},
+			"index": {
+				Type:     types.Int64Type,
+				Computed: true,
+			},
+			"enabled": {
+				Type:     types.BoolType,
+				Computed: true,
+			},
+			"name": {
+				Type:        types.StringType,
+				Description: "The name of the rule, compared ignoring case",
+				Optional:    true,
+				Computed:    true,
+			},
+			"protocol": {
+				Type:        types.StringType,
+				Description: "The protocol of the rule, used together with wan_port_start",
+				Optional:    true,
+				Computed:    true,
+			},
+			"wan_port_start": {
+				Type:        types.Int64Type,
+				Description: "The first wan port of the rule, used together with protocol",
+				Optional:    true,
+				Computed:    true,
+			},
+			"wan_port_end": {
+				Type:     types.Int64Type,
+				Computed: true,
+			},
+			"lan_port_start": {
+				Type:     types.Int64Type,
+				Computed: true,
+			},
+			"lan_port_end": {
+				Type:     types.Int64Type,
+				Computed: true,
+			},
+			"lan_ip": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+			"lan_mac": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+		},
+	}, nil
+}
+
+func (c datasourcePortForwardedType) NewDataSource(_ context.Context,
+	p tfsdk.Provider) (tfsdk.DataSource, diag.Diagnostics) {
+	return datasourcePortForwarded{
+		p: *(p.(*provider)),
+	}, nil
+}
+
+type datasourcePortForwarded struct {
+	p provider
+}
+
+func (r datasourcePortForwarded) Read(ctx context.Context, req tfsdk.ReadDataSourceRequest, resp *tfsdk.ReadDataSourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var config PortForwarded
+
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	byID := !config.ID.Null
+	byName := !config.Name.Null
+	byPort := !config.Protocol.Null && !config.WanPortStart.Null
+
+	if !byID && !byName && !byPort {
+		resp.Diagnostics.AddError(
+			"Missing lookup key",
+			"One of id, name or wan_port_start together with protocol must be set",
+		)
+		return
+	}
+
+	var err, ports = r.p.router.GetAllPortForwarded(ctx)
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to get port forwarded list", err)
+		return
+	}
+
+	var matches []PortForwarded
+
+	for _, port := range ports {
+		model := portForwardedToModel(port)
+
+		if byID && !strings.EqualFold(model.ID.Value, config.ID.Value) {
+			continue
+		}
+		if byName && !strings.EqualFold(model.Name.Value, config.Name.Value) {
+			continue
+		}
+		if !config.Protocol.Null && !strings.EqualFold(model.Protocol.Value, config.Protocol.Value) {
+			continue
+		}
+		if !config.WanPortStart.Null && model.WanPortStart.Value != config.WanPortStart.Value {
+			continue
+		}
+
+		matches = append(matches, model)
+	}
+
+	if len(matches) == 0 {
+		resp.Diagnostics.AddError(
+			"Port forwarding not found",
+			"No port forwarding matches the given lookup keys",
+		)
+		return
+	}
+
+	if len(matches) > 1 {
+		var ids []string
+		for _, match := range matches {
+			ids = append(ids, fmt.Sprintf("%s (index %d)", match.ID.Value, match.Index.Value))
+		}
+		resp.Diagnostics.AddError(
+			"Multiple port forwardings found",
+			fmt.Sprintf("The lookup keys match %d port forwardings: %s. Use more specific keys.", len(matches), strings.Join(ids, ", ")),
+		)
+		return
+	}
+
+	diags = resp.State.Set(ctx, &matches[0])
+	resp.Diagnostics.Append(diags...)
+}
@@ -2,11 +2,16 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 )
 
 type datasourcePortForwardedListType struct{}
@@ -15,6 +20,50 @@ func (c datasourcePortForwardedListType) GetSchema(_ context.Context) (tfsdk.Sch
 	diag.Diagnostics) {
 	return tfsdk.Schema{
 		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+			"protocol": {
+				Type:        types.StringType,
+				Description: "Only return rules with this protocol",
+				Optional:    true,
+			},
+			"enabled": {
+				Type:        types.BoolType,
+				Description: "Only return enabled (or disabled) rules",
+				Optional:    true,
+			},
+			"lan_ip": {
+				Type:        types.StringType,
+				Description: "Only return rules forwarding to this lan ip",
+				Optional:    true,
+			},
+			"lan_mac": {
+				Type:        types.StringType,
+				Description: "Only return rules forwarding to this lan mac address",
+				Optional:    true,
+			},
+			"name_regex": {
+				Type:        types.StringType,
+				Description: "Only return rules whose name matches this regular expression",
+				Optional:    true,
+			},
+			"wan_port_min": {
+				Type:        types.Int64Type,
+				Description: "Only return rules whose wan ports overlap the range starting at this port",
+				Optional:    true,
+			},
+			"wan_port_max": {
+				Type:        types.Int64Type,
+				Description: "Only return rules whose wan ports overlap the range ending at this port",
+				Optional:    true,
+			},
+			"sort_by": {
+				Type:        types.StringType,
+				Description: "Sort the rules by index, wan_port or lan_port (Default: index)",
+				Optional:    true,
+			},
 			"ports": {
 				// When Computed is true, the provider will set value --
 				// the user cannot define the value
@@ -45,12 +94,22 @@ func (c datasourcePortForwardedListType) GetSchema(_ context.Context) (tfsdk.Sch
 						Computed: true,
 						Required: false,
 					},
-					"wan_port": {
+					"wan_port_start": {
+						Type:     types.Int64Type,
+						Computed: true,
+						Required: false,
+					},
+					"wan_port_end": {
+						Type:     types.Int64Type,
+						Computed: true,
+						Required: false,
+					},
+					"lan_port_start": {
 						Type:     types.Int64Type,
 						Computed: true,
 						Required: false,
 					},
-					"lan_port": {
+					"lan_port_end": {
 						Type:     types.Int64Type,
 						Computed: true,
 						Required: false,
@@ -91,8 +150,20 @@ func (r datasourcePortForwardedList) Read(ctx context.Context, req tfsdk.ReadDat
 		return
 	}
 
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
 	var resourceState struct {
-		Ports []PortForwarded `tfsdk:"ports"`
+		ID         types.String    `tfsdk:"id"`
+		Protocol   types.String    `tfsdk:"protocol"`
+		Enabled    types.Bool      `tfsdk:"enabled"`
+		LanIp      types.String    `tfsdk:"lan_ip"`
+		LanMac     types.String    `tfsdk:"lan_mac"`
+		NameRegex  types.String    `tfsdk:"name_regex"`
+		WanPortMin types.Int64     `tfsdk:"wan_port_min"`
+		WanPortMax types.Int64     `tfsdk:"wan_port_max"`
+		SortBy     types.String    `tfsdk:"sort_by"`
+		Ports      []PortForwarded `tfsdk:"ports"`
 	}
 
 	diags := req.Config.Get(ctx, &resourceState)
@@ -101,33 +172,88 @@ func (r datasourcePortForwardedList) Read(ctx context.Context, req tfsdk.ReadDat
 		return
 	}
 
-	var err, ports = r.p.router.GetAllPortForwarded()
+	var nameRegex *regexp.Regexp
 
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get port forwarded list",
-			"Failed to get port forwarded list from router",
+	if !resourceState.NameRegex.Null {
+		var err error
+		nameRegex, err = regexp.Compile(resourceState.NameRegex.Value)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("name_regex"),
+				"Invalid name_regex",
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	sortBy := "index"
+	if !resourceState.SortBy.Null {
+		sortBy = resourceState.SortBy.Value
+	}
+
+	if sortBy != "index" && sortBy != "wan_port" && sortBy != "lan_port" {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("sort_by"),
+			"Invalid sort_by",
+			fmt.Sprintf("sort_by must be one of index, wan_port or lan_port, got %q", sortBy),
 		)
 		return
 	}
 
+	var err, ports = r.p.router.GetAllPortForwarded(ctx)
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to get port forwarded list", err)
+		return
+	}
+
 	log.Printf("[INFO] Found %d forwarded ports", len(ports))
 
+	resourceState.Ports = nil
+
 	for _, port := range ports {
-		resourceState.Ports = append(resourceState.Ports, PortForwarded{
-			// ID:       types.String(port.Data.ID),
-			ID:       types.String{Value: computeID(port.Data.Name, port.Data.WanPort, port.Data.Protocol)},
-			Index:    types.Int64{Value: int64(port.Index)},
-			Enabled:  types.Bool{Value: port.Data.Enabled},
-			Name:     types.String{Value: port.Data.Name},
-			Protocol: types.String{Value: port.Data.Protocol},
-			WanPort:  types.Int64{Value: int64(port.Data.WanPort)},
-			LanPort:  types.Int64{Value: int64(port.Data.LanPort)},
-			LanIp:    types.String{Value: port.Data.LanIp},
-			LanMac:   types.String{Value: port.Data.LanMac},
-		})
+		if !resourceState.Protocol.Null && !strings.EqualFold(port.Data.Protocol, resourceState.Protocol.Value) {
+			continue
+		}
+		if !resourceState.Enabled.Null && port.Data.Enabled != resourceState.Enabled.Value {
+			continue
+		}
+		if !resourceState.LanIp.Null && port.Data.LanIp != resourceState.LanIp.Value {
+			continue
+		}
+		if !resourceState.LanMac.Null && !strings.EqualFold(port.Data.LanMac, resourceState.LanMac.Value) {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(port.Data.Name) {
+			continue
+		}
+		if !resourceState.WanPortMin.Null && int64(port.Data.WanPortEnd) < resourceState.WanPortMin.Value {
+			continue
+		}
+		if !resourceState.WanPortMax.Null && int64(port.Data.WanPortStart) > resourceState.WanPortMax.Value {
+			continue
+		}
+		resourceState.Ports = append(resourceState.Ports, portForwardedToModel(port))
 	}
 
+	log.Printf("[INFO] %d forwarded ports match the filters", len(resourceState.Ports))
+
+	// data sources need an id, the router only has one table
+	resourceState.ID = types.String{Value: "port_forwarded_list"}
+
+	sort.SliceStable(resourceState.Ports, func(i, j int) bool {
+		a, b := resourceState.Ports[i], resourceState.Ports[j]
+		switch sortBy {
+		case "wan_port":
+			return a.WanPortStart.Value < b.WanPortStart.Value
+		case "lan_port":
+			return a.LanPortStart.Value < b.LanPortStart.Value
+		default:
+			return a.Index.Value < b.Index.Value
+		}
+	})
+
 	diags = resp.State.Set(ctx, &resourceState)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
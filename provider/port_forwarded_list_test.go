@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"terraform-provider-technicolor/technicolor"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+var testAccPortsForwarded = []technicolor.PortForwarded{
+	{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 2222, WanPortEnd: 2222, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"},
+	{Enabled: false, Name: "rtp", Protocol: "UDP", WanPortStart: 5000, WanPortEnd: 5100, LanPortStart: 5000, LanPortEnd: 5100, LanIp: "192.168.1.20", LanMac: "02:00:c0:a8:01:14"},
+	{Enabled: true, Name: "web", Protocol: "TCP", WanPortStart: 80, WanPortEnd: 80, LanPortStart: 8080, LanPortEnd: 8080, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"},
+}
+
+func TestAccDataSourcePortForwardedList(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.SetPortForwarded(testAccPortsForwarded)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded_list" "all" {}
+
+data "technicolor_port_forwarded_list" "filtered" {
+  protocol = "TCP"
+  lan_ip   = "192.168.1.10"
+  sort_by  = "wan_port"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "3"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.1.id", "rtp/UDP/5000"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.1.index", "2"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.1.enabled", "false"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.1.wan_port_end", "5100"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.1.lan_mac", "02:00:c0:a8:01:14"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.filtered", "ports.#", "2"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.filtered", "ports.0.name", "web"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.filtered", "ports.1.name", "ssh"),
+				),
+			},
+		},
+	})
+}
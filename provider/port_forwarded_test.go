@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourcePortForwarded(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.SetPortForwarded(testAccPortsForwarded)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded" "by_name" {
+  name = "WEB"
+}
+
+data "technicolor_port_forwarded" "by_port" {
+  protocol       = "UDP"
+  wan_port_start = 5000
+}
+
+data "technicolor_port_forwarded" "by_id" {
+  id = "ssh/TCP/2222"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded.by_name", "lan_port_start", "8080"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded.by_name", "index", "3"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded.by_port", "name", "rtp"),
+					resource.TestCheckResourceAttr("data.technicolor_port_forwarded.by_id", "lan_ip", "192.168.1.10"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded" "by_ip" {
+  protocol = "TCP"
+}
+`,
+				ExpectError: regexp.MustCompile(`Missing lookup key`),
+			},
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded" "missing" {
+  name = "missing"
+}
+`,
+				ExpectError: regexp.MustCompile(`Port forwarding not found`),
+			},
+		},
+	})
+}
@@ -0,0 +1,380 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"terraform-provider-technicolor/technicolor"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// portForwardingAttributes maps the form parameters of the port forwarding
+// table to the attributes, to report the values refused by the router
+var portForwardingAttributes = map[string]string{
+	"enabled":       "enabled",
+	"name":          "name",
+	"protocol":      "protocol",
+	"wanport":       "wan_port_start",
+	"lanport":       "lan_port_start",
+	"destinationip": "lan_ip",
+}
+
+type resourcePortForwardingType struct{}
+
+func (c resourcePortForwardingType) GetSchema(_ context.Context) (tfsdk.Schema,
+	diag.Diagnostics) {
+	return tfsdk.Schema{
+		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Type:     types.StringType,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					tfsdk.UseStateForUnknown(),
+				},
+			},
+			"index": {
+				Type:     types.Int64Type,
+				Computed: true,
+			},
+			"enabled": {
+				Type:     types.BoolType,
+				Required: true,
+			},
+			"name": {
+				Type:     types.StringType,
+				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					tfsdk.RequiresReplace(),
+				},
+			},
+			"protocol": {
+				Type:     types.StringType,
+				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					tfsdk.RequiresReplace(),
+				},
+			},
+			"wan_port_start": {
+				Type:     types.Int64Type,
+				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					tfsdk.RequiresReplace(),
+				},
+			},
+			"wan_port_end": {
+				Type:        types.Int64Type,
+				Description: "The last port of the WAN range (Default: wan_port_start)",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultToAttribute("wan_port_start"),
+				},
+			},
+			"lan_port_start": {
+				Type:     types.Int64Type,
+				Required: true,
+			},
+			"lan_port_end": {
+				Type:        types.Int64Type,
+				Description: "The last port of the LAN range (Default: lan_port_start)",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultToAttribute("lan_port_start"),
+				},
+			},
+			"lan_ip": {
+				Type:     types.StringType,
+				Required: true,
+			},
+			"lan_mac": {
+				// the mac address follows lan_ip, it may change on update
+				Type:     types.StringType,
+				Computed: true,
+			},
+		},
+	}, nil
+}
+
+func (c resourcePortForwardingType) NewResource(_ context.Context,
+	p tfsdk.Provider) (tfsdk.Resource, diag.Diagnostics) {
+	return resourcePortForwarding{
+		p: *(p.(*provider)),
+	}, nil
+}
+
+type resourcePortForwarding struct {
+	p provider
+}
+
+func (r resourcePortForwarding) Create(ctx context.Context, req tfsdk.CreateResourceRequest, resp *tfsdk.CreateResourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var plan PortForwarded
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	portForwarded := portForwardedFromModel(&plan)
+
+	err := r.p.router.AddPortForwarded(ctx, &portForwarded)
+
+	if err != nil {
+		addRouterWriteError(&resp.Diagnostics, "Failed to create port forwarding", err, portForwardingAttributes)
+		return
+	}
+
+	err, created := r.p.router.FindPortForwarded(ctx, portForwarded.Identity())
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to read port forwarding after creation", err)
+		return
+	}
+
+	state := portForwardedToModel(created)
+
+	log.Printf("[INFO] Created port forwarding %s at index %d", state.ID.Value, created.Index)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r resourcePortForwarding) Read(ctx context.Context, req tfsdk.ReadResourceRequest, resp *tfsdk.ReadResourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var state PortForwarded
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err, portForwarded := r.p.router.FindPortForwarded(ctx, portForwardedIdentityFromModel(&state))
+
+	if errors.Is(err, technicolor.ErrPortForwardedNotFound) {
+		// the rule was removed outside of terraform
+		log.Printf("[WARN] Port forwarding %s not found, removing from state", state.ID.Value)
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to read port forwarding", err)
+		return
+	}
+
+	state = portForwardedToModel(portForwarded)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r resourcePortForwarding) Update(ctx context.Context, req tfsdk.UpdateResourceRequest, resp *tfsdk.UpdateResourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var plan PortForwarded
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var state PortForwarded
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	portForwarded := portForwardedFromModel(&plan)
+
+	// the client resolves the current index of the rule before modifying it
+	err := r.p.router.UpdatePortForwardedByIdentity(ctx, portForwardedIdentityFromModel(&state), &portForwarded)
+
+	if err != nil {
+		addRouterWriteError(&resp.Diagnostics, "Failed to update port forwarding", err, portForwardingAttributes)
+		return
+	}
+
+	// the mac address follows the new lan ip, do not use it for the lookup
+	identity := portForwarded.Identity()
+	identity.LanMac = ""
+
+	err, updated := r.p.router.FindPortForwarded(ctx, identity)
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to read port forwarding after update", err)
+		return
+	}
+
+	state = portForwardedToModel(updated)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (r resourcePortForwarding) Delete(ctx context.Context, req tfsdk.DeleteResourceRequest, resp *tfsdk.DeleteResourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var state PortForwarded
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// the client resolves the current index of the rule before deleting it
+	err := r.p.router.DeletePortForwardedByIdentity(ctx, portForwardedIdentityFromModel(&state))
+
+	if errors.Is(err, technicolor.ErrPortForwardedNotFound) {
+		log.Printf("[WARN] Port forwarding %s already deleted", state.ID.Value)
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to delete port forwarding", err)
+		return
+	}
+
+	resp.State.RemoveResource(ctx)
+}
+
+// ImportState accepts either the computed id (name/protocol/wanport),
+// as exposed by the technicolor_port_forwarded_list data source,
+// or the bare index of the rule in the router table
+func (r resourcePortForwarding) ImportState(ctx context.Context, req tfsdk.ImportResourceStateRequest, resp *tfsdk.ImportResourceStateResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var err error
+	var portForwarded technicolor.PortForwardedWithIndex
+
+	if index, convErr := strconv.Atoi(req.ID); convErr == nil {
+		err, portForwarded = findPortForwardedByIndex(ctx, r.p.router, index)
+	} else if identity, parseErr := parseID(req.ID); parseErr == nil {
+		err, portForwarded = r.p.router.FindPortForwarded(ctx, identity)
+	} else {
+		err = parseErr
+	}
+
+	if errors.Is(err, technicolor.ErrPortForwardedNotFound) {
+		resp.Diagnostics.AddError(
+			"Port forwarding not found",
+			fmt.Sprintf("No port forwarding matches %q, expected either name/protocol/wanport or the index of the rule", req.ID),
+		)
+		return
+	}
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to import port forwarding", err)
+		return
+	}
+
+	state := portForwardedToModel(portForwarded)
+
+	diags := resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func findPortForwardedByIndex(ctx context.Context, router *technicolor.TechnicolorRouter, index int) (err error, portForwarded technicolor.PortForwardedWithIndex) {
+	err, portsForwarded := router.GetAllPortForwarded(ctx)
+
+	if err != nil {
+		return err, technicolor.PortForwardedWithIndex{Index: -1}
+	}
+
+	for _, portForwarded := range portsForwarded {
+		if portForwarded.Index == index {
+			return nil, portForwarded
+		}
+	}
+
+	return technicolor.ErrPortForwardedNotFound, technicolor.PortForwardedWithIndex{Index: -1}
+}
+
+// defaultToAttribute plans the value of another attribute when the
+// attribute is not set, e.g. the end of a range defaults to its start
+func defaultToAttribute(attribute string) tfsdk.AttributePlanModifier {
+	return defaultToAttributeModifier{attribute: attribute}
+}
+
+type defaultToAttributeModifier struct {
+	attribute string
+}
+
+func (m defaultToAttributeModifier) Description(_ context.Context) string {
+	return fmt.Sprintf("Defaults to the value of %s", m.attribute)
+}
+
+func (m defaultToAttributeModifier) MarkdownDescription(ctx context.Context) string {
+	return m.Description(ctx)
+}
+
+func (m defaultToAttributeModifier) Modify(ctx context.Context, req tfsdk.ModifyAttributePlanRequest, resp *tfsdk.ModifyAttributePlanResponse) {
+	// the resource is destroyed or the attribute is set
+	if req.Plan.Raw.IsNull() || !req.AttributeConfig.(types.Int64).Null {
+		return
+	}
+
+	var value types.Int64
+	diags := req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName(m.attribute), &value)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.AttributePlan = value
+}
@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"terraform-provider-technicolor/technicolor"
+	"terraform-provider-technicolor/technicolortest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func testAccPortForwardingConfig(gateway *technicolortest.Gateway, enabled bool, lanIp string) string {
+	return testAccProviderConfig(gateway) + fmt.Sprintf(`
+resource "technicolor_port_forwarding" "test" {
+  enabled        = %t
+  name           = "game"
+  protocol       = "UDP"
+  wan_port_start = 27015
+  wan_port_end   = 27030
+  lan_port_start = 27015
+  lan_ip         = %q
+}
+`, enabled, lanIp)
+}
+
+// testAccPortForwardingConfigSinglePort leaves out both ends of the ranges
+func testAccPortForwardingConfigSinglePort(gateway *technicolortest.Gateway, lanPortStart int) string {
+	return testAccProviderConfig(gateway) + fmt.Sprintf(`
+resource "technicolor_port_forwarding" "test" {
+  enabled        = false
+  name           = "game"
+  protocol       = "UDP"
+  wan_port_start = 27015
+  lan_port_start = %d
+  lan_ip         = "192.168.1.31"
+}
+`, lanPortStart)
+}
+
+func testAccCheckGatewayPortForwarded(gateway *technicolortest.Gateway, expected ...technicolor.PortForwarded) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		actual := gateway.PortForwarded()
+		if len(actual) != len(expected) {
+			return fmt.Errorf("expected %d port forwardings on the gateway, got %d: %+v", len(expected), len(actual), actual)
+		}
+		for i := range expected {
+			if actual[i] != expected[i] {
+				return fmt.Errorf("port forwarding %d: expected %+v, got %+v", i+1, expected[i], actual[i])
+			}
+		}
+		return nil
+	}
+}
+
+func testAccCheckGatewayPortForwardedDestroyed(gateway *technicolortest.Gateway, identity technicolor.PortForwardedIdentity) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		for _, portForwarded := range gateway.PortForwarded() {
+			if portForwarded.Name == identity.Name && portForwarded.Protocol == identity.Protocol && portForwarded.WanPortStart == identity.WanPortStart {
+				return fmt.Errorf("port forwarding %s still exists on the gateway", identity.Name)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccResourcePortForwarding(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.SetPortForwarded(testAccPortsForwarded[:1])
+
+	game := technicolor.PortForwarded{
+		Enabled: true, Name: "game", Protocol: "UDP", WanPortStart: 27015, WanPortEnd: 27030, LanPortStart: 27015, LanPortEnd: 27015, LanIp: "192.168.1.30", LanMac: "02:00:c0:a8:01:1e",
+	}
+	gameUpdated := game
+	gameUpdated.Enabled = false
+	gameUpdated.LanIp = "192.168.1.31"
+	gameUpdated.LanMac = "02:00:c0:a8:01:1f"
+
+	gameSinglePort := func(lanPort int) technicolor.PortForwarded {
+		portForwarded := gameUpdated
+		portForwarded.WanPortEnd = portForwarded.WanPortStart
+		portForwarded.LanPortStart = lanPort
+		portForwarded.LanPortEnd = lanPort
+		return portForwarded
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPortForwardingConfig(gateway, true, "192.168.1.30"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "id", "game/UDP/27015"),
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "index", "2"),
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "lan_port_end", "27015"),
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "lan_mac", game.LanMac),
+					testAccCheckGatewayPortForwarded(gateway, testAccPortsForwarded[0], game),
+				),
+			},
+			{
+				ResourceName:      "technicolor_port_forwarding.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:      "technicolor_port_forwarding.test",
+				ImportState:       true,
+				ImportStateId:     "2",
+				ImportStateVerify: true,
+			},
+			{
+				// in place update, the rule keeps its position
+				Config: testAccPortForwardingConfig(gateway, false, "192.168.1.31"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "enabled", "false"),
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "lan_mac", gameUpdated.LanMac),
+					testAccCheckGatewayPortForwarded(gateway, testAccPortsForwarded[0], gameUpdated),
+				),
+			},
+			{
+				// the first rule is removed from the web UI, the index shifts
+				PreConfig: func() {
+					gateway.SetPortForwarded([]technicolor.PortForwarded{gameUpdated})
+				},
+				Config: testAccPortForwardingConfig(gateway, false, "192.168.1.31"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "index", "1"),
+					testAccCheckGatewayPortForwarded(gateway, gameUpdated),
+				),
+			},
+			{
+				// the lan ip is changed from the web UI, terraform reverts it
+				PreConfig: func() {
+					gateway.SetPortForwarded([]technicolor.PortForwarded{game})
+				},
+				Config: testAccPortForwardingConfig(gateway, false, "192.168.1.31"),
+				Check:  testAccCheckGatewayPortForwarded(gateway, gameUpdated),
+			},
+			{
+				// the rule is deleted from the web UI, terraform recreates it
+				PreConfig: func() {
+					gateway.SetPortForwarded(nil)
+				},
+				Config: testAccPortForwardingConfig(gateway, false, "192.168.1.31"),
+				Check:  testAccCheckGatewayPortForwarded(gateway, gameUpdated),
+			},
+			{
+				// without their end the ranges become single ports again,
+				// the end follows the new start
+				Config: testAccPortForwardingConfigSinglePort(gateway, 20000),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "wan_port_end", "27015"),
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "lan_port_end", "20000"),
+					testAccCheckGatewayPortForwarded(gateway, gameSinglePort(20000)),
+				),
+			},
+			{
+				// a start after the previous end
+				Config: testAccPortForwardingConfigSinglePort(gateway, 28000),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "lan_port_end", "28000"),
+					testAccCheckGatewayPortForwarded(gateway, gameSinglePort(28000)),
+				),
+			},
+		},
+		CheckDestroy: testAccCheckGatewayPortForwardedDestroyed(gateway, game.Identity()),
+	})
+}
+
+func TestAccResourcePortForwardingValidation(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.SetPortForwarded(testAccPortsForwarded[:1])
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// the router refuses the ip, nothing is created
+				Config:      testAccPortForwardingConfig(gateway, true, "192.168.1.300"),
+				ExpectError: regexp.MustCompile(`(?s)lan_ip.*The\s+router\s+refused\s+the\s+value:\s+Invalid\s+IPv4\s+address`),
+			},
+			{
+				Config: testAccPortForwardingConfig(gateway, true, "192.168.1.30"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("technicolor_port_forwarding.test", "index", "2"),
+				),
+			},
+		},
+	})
+}
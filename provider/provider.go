@@ -2,13 +2,20 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"terraform-provider-technicolor/technicolor"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 )
 
 var stderr = os.Stderr
@@ -25,6 +32,16 @@ type provider struct {
 	configured bool
 	version    string
 	router     *technicolor.TechnicolorRouter
+	timeout    time.Duration
+}
+
+// withTimeout bounds a whole provider operation, so that a router which
+// stops answering cannot hang terraform
+func (p *provider) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.timeout)
 }
 
 // func (p *provider) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
@@ -52,10 +69,30 @@ func (p *provider) GetSchema(_ context.Context) (tfsdk.Schema, diag.Diagnostics)
 			},
 			"port": {
 				Type:        types.Int64Type,
-				Description: "The port of the Technicolor router (Default: 80)",
+				Description: "The port of the Technicolor router (Default: 80, 443 with https)",
 				Optional:    true,
 				Required:    false,
 			},
+			"scheme": {
+				Type:        types.StringType,
+				Description: "The scheme of the web UI, http or https (Default: http)",
+				Optional:    true,
+			},
+			"ca_certificate": {
+				Type:        types.StringType,
+				Description: "PEM encoded CA certificates trusted to sign the router certificate with https, on top of the system ones; conflicts with certificate_fingerprint and insecure_skip_verify",
+				Optional:    true,
+			},
+			"certificate_fingerprint": {
+				Type:        types.StringType,
+				Description: "SHA-256 fingerprint of the router certificate with https, like AB:CD:...; only that certificate is accepted, which suits the self-signed certificate of the router; conflicts with ca_certificate and insecure_skip_verify",
+				Optional:    true,
+			},
+			"insecure_skip_verify": {
+				Type:        types.BoolType,
+				Description: "Accept any router certificate with https, the connection is encrypted but not authenticated; conflicts with ca_certificate and certificate_fingerprint (Default: false)",
+				Optional:    true,
+			},
 			"username": {
 				Type:     types.StringType,
 				Optional: false,
@@ -69,6 +106,62 @@ func (p *provider) GetSchema(_ context.Context) (tfsdk.Schema, diag.Diagnostics)
 				Sensitive: true,
 				Required:  true,
 			},
+			"session_id": {
+				Type:        types.StringType,
+				Description: "The sessionID cookie of an existing session to reuse instead of opening a new one, for example from a previous run. It is not logged out when the provider exits. If it is expired the provider logs in with username and password.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"srp_hash": {
+				Type:        types.StringType,
+				Description: "The hash of the SRP login: sha1, sha224, sha256, sha384 or sha512 (Default: sha256)",
+				Optional:    true,
+			},
+			"srp_group": {
+				Type:        types.Int64Type,
+				Description: "The size in bits of the standard SRP group of the login: 1024, 2048, 4096 or 8192 (Default: 2048). Conflicts with srp_n and srp_g.",
+				Optional:    true,
+			},
+			"srp_n": {
+				Type:        types.StringType,
+				Description: "The hex prime N of a custom SRP group, for firmwares not using a standard one. Requires srp_g.",
+				Optional:    true,
+			},
+			"srp_g": {
+				Type:        types.StringType,
+				Description: "The hex generator g of a custom SRP group. Requires srp_n.",
+				Optional:    true,
+			},
+			"request_timeout": {
+				Type:        types.StringType,
+				Description: "The maximum duration of a single request to the router, as a Go duration like 30s (Default: 30s)",
+				Optional:    true,
+			},
+			"retries": {
+				Type:        types.Int64Type,
+				Description: "How many times a request failed for a transient reason, like a 5xx answer or a dropped connection, is sent again. Reads are always retried, writes only when the provider can check the router did not apply them (Default: 3)",
+				Optional:    true,
+			},
+			"retry_backoff": {
+				Type:        types.StringType,
+				Description: "The wait before the first retry, doubled at every retry, as a Go duration like 500ms (Default: 500ms)",
+				Optional:    true,
+			},
+			"retry_max_backoff": {
+				Type:        types.StringType,
+				Description: "The maximum wait between retries, as a Go duration like 10s (Default: 10s)",
+				Optional:    true,
+			},
+			"min_request_interval": {
+				Type:        types.StringType,
+				Description: "The minimum interval between two requests to the router, to avoid overwhelming it, as a Go duration like 100ms (Default: 0s)",
+				Optional:    true,
+			},
+			"timeout": {
+				Type:        types.StringType,
+				Description: "The maximum duration of every provider operation, including all its requests, as a Go duration like 5m (Default: 5m)",
+				Optional:    true,
+			},
 		},
 	}, nil
 }
@@ -77,10 +170,32 @@ func (p *provider) GetSchema(_ context.Context) (tfsdk.Schema, diag.Diagnostics)
 type providerData struct {
 	Host     types.String `tfsdk:"host"`
 	Port     types.Int64  `tfsdk:"port"`
+	Scheme   types.String `tfsdk:"scheme"`
 	Username types.String `tfsdk:"username"`
 	Password types.String `tfsdk:"password"`
+
+	SessionID types.String `tfsdk:"session_id"`
+
+	SRPHash  types.String `tfsdk:"srp_hash"`
+	SRPGroup types.Int64  `tfsdk:"srp_group"`
+	SRPN     types.String `tfsdk:"srp_n"`
+	SRPG     types.String `tfsdk:"srp_g"`
+
+	CACertificate          types.String `tfsdk:"ca_certificate"`
+	CertificateFingerprint types.String `tfsdk:"certificate_fingerprint"`
+	InsecureSkipVerify     types.Bool   `tfsdk:"insecure_skip_verify"`
+
+	RequestTimeout types.String `tfsdk:"request_timeout"`
+	Timeout        types.String `tfsdk:"timeout"`
+
+	Retries            types.Int64  `tfsdk:"retries"`
+	RetryBackoff       types.String `tfsdk:"retry_backoff"`
+	RetryMaxBackoff    types.String `tfsdk:"retry_max_backoff"`
+	MinRequestInterval types.String `tfsdk:"min_request_interval"`
 }
 
+const DEFAULT_TIMEOUT = 5 * time.Minute
+
 func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderRequest, resp *tfsdk.ConfigureProviderResponse) {
 	// Retrieve provider data from configuration
 	var config providerData
@@ -95,6 +210,7 @@ func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderReq
 	var port int
 	var username string
 	var password string
+	var sessionID string
 
 	if !checkForUnknowsInConfig(&config, resp) {
 		return
@@ -112,9 +228,72 @@ func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderReq
 		username = config.Username.Value
 	}
 
+	scheme := getStringConfig(config.Scheme, "TECHNICOLOR_SCHEME")
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	if scheme != "http" && scheme != "https" {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("scheme"),
+			"Invalid scheme",
+			"The scheme must be http or https",
+		)
+		return
+	}
+
+	tlsOptions := technicolor.TLSOptions{
+		CACertificates: []byte(getStringConfig(config.CACertificate, "TECHNICOLOR_CA_CERTIFICATE")),
+		Fingerprint:    getStringConfig(config.CertificateFingerprint, "TECHNICOLOR_CERTIFICATE_FINGERPRINT"),
+	}
+
+	tlsOptions.InsecureSkipVerify, err = getBoolConfig(config.InsecureSkipVerify, "TECHNICOLOR_INSECURE_SKIP_VERIFY")
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("insecure_skip_verify"),
+			"Invalid insecure_skip_verify",
+			err.Error(),
+		)
+		return
+	}
+
+	if scheme == "http" && (len(tlsOptions.CACertificates) > 0 || tlsOptions.Fingerprint != "" || tlsOptions.InsecureSkipVerify) {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("scheme"),
+			"Invalid scheme",
+			"ca_certificate, certificate_fingerprint and insecure_skip_verify require the https scheme",
+		)
+		return
+	}
+
+	// each option is a different way to trust the router certificate
+	var tlsAttributes []string
+	if len(tlsOptions.CACertificates) > 0 {
+		tlsAttributes = append(tlsAttributes, "ca_certificate")
+	}
+	if tlsOptions.Fingerprint != "" {
+		tlsAttributes = append(tlsAttributes, "certificate_fingerprint")
+	}
+	if tlsOptions.InsecureSkipVerify {
+		tlsAttributes = append(tlsAttributes, "insecure_skip_verify")
+	}
+
+	if len(tlsAttributes) > 1 {
+		for _, attribute := range tlsAttributes {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName(attribute),
+				"Conflicting TLS options",
+				fmt.Sprintf("Only one of %s can be set", strings.Join(tlsAttributes, ", ")),
+			)
+		}
+		return
+	}
+
 	if config.Port.Null {
 		portString := os.Getenv("TECHNICOLOR_PORT")
-		if portString == "" {
+		if portString == "" && scheme == "https" {
+			port = 443
+		} else if portString == "" {
 			port = 80
 		} else {
 			port, err = strconv.Atoi(portString)
@@ -136,6 +315,12 @@ func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderReq
 		password = config.Password.Value
 	}
 
+	if config.SessionID.Null {
+		sessionID = os.Getenv("TECHNICOLOR_SESSION_ID")
+	} else {
+		sessionID = config.SessionID.Value
+	}
+
 	if username == "" {
 		resp.Diagnostics.AddError(
 			"Unable to find username",
@@ -144,64 +329,289 @@ func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderReq
 		return
 	}
 
-	p.router = technicolor.NewTechnicolorRouter(host, port)
+	requestTimeout, err := getDurationConfig(config.RequestTimeout, "TECHNICOLOR_REQUEST_TIMEOUT", technicolor.DEFAULT_REQUEST_TIMEOUT)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("request_timeout"),
+			"Invalid request timeout",
+			err.Error(),
+		)
+		return
+	}
 
-	err, isAuthenticated := p.router.Login(username, password)
+	p.timeout, err = getDurationConfig(config.Timeout, "TECHNICOLOR_TIMEOUT", DEFAULT_TIMEOUT)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("timeout"),
+			"Invalid timeout",
+			err.Error(),
+		)
+		return
+	}
 
+	retries, err := getIntConfig(config.Retries, "TECHNICOLOR_RETRIES", technicolor.DEFAULT_RETRIES)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to login",
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("retries"),
+			"Invalid retries",
 			err.Error(),
 		)
 		return
 	}
 
-	if !isAuthenticated {
-		resp.Diagnostics.AddError(
-			"Unable to login",
-			"Username or password is incorrect",
+	retryBackoff, err := getDurationConfig(config.RetryBackoff, "TECHNICOLOR_RETRY_BACKOFF", technicolor.DEFAULT_RETRY_BACKOFF)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("retry_backoff"),
+			"Invalid retry backoff",
+			err.Error(),
 		)
 		return
 	}
 
-	p.configured = true
-}
+	retryMaxBackoff, err := getDurationConfig(config.RetryMaxBackoff, "TECHNICOLOR_RETRY_MAX_BACKOFF", technicolor.DEFAULT_RETRY_MAX_BACKOFF)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("retry_max_backoff"),
+			"Invalid retry max backoff",
+			err.Error(),
+		)
+		return
+	}
 
-func checkForUnknowsInConfig(config *providerData, resp *tfsdk.ConfigureProviderResponse) bool {
-	if config.Host.Unknown {
-		resp.Diagnostics.AddWarning(
-			"Unable to create client",
-			"Cannot use unknown value as host",
+	minRequestInterval, err := getDurationConfig(config.MinRequestInterval, "TECHNICOLOR_MIN_REQUEST_INTERVAL", 0)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("min_request_interval"),
+			"Invalid min request interval",
+			err.Error(),
 		)
-		return false
+		return
+	}
+
+	hashName := getStringConfig(config.SRPHash, "TECHNICOLOR_SRP_HASH")
+	if hashName == "" {
+		hashName = "sha256"
 	}
 
-	if config.Username.Unknown {
-		resp.Diagnostics.AddWarning(
-			"Unable to create client",
-			"Cannot use unknown value as username",
+	hashAlgorithm, ok := technicolor.HASH_ALGORITHMS[strings.ToLower(hashName)]
+	if !ok {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("srp_hash"),
+			"Invalid SRP hash",
+			fmt.Sprintf("Unknown hash %q, it must be one of sha1, sha224, sha256, sha384 or sha512", hashName),
 		)
-		return false
+		return
 	}
 
-	if config.Password.Unknown {
-		resp.Diagnostics.AddWarning(
-			"Unable to create client",
-			"Cannot use unknown value as password",
+	ngType, err := getIntConfig(config.SRPGroup, "TECHNICOLOR_SRP_GROUP", technicolor.NG_2048)
+	if err == nil {
+		if _, ok := technicolor.N_CONSTANTS[ngType]; !ok {
+			err = fmt.Errorf("unknown group %d, it must be one of 1024, 2048, 4096 or 8192", ngType)
+		}
+	}
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("srp_group"),
+			"Invalid SRP group",
+			err.Error(),
 		)
-		return false
+		return
+	}
+
+	nHex := getStringConfig(config.SRPN, "TECHNICOLOR_SRP_N")
+	gHex := getStringConfig(config.SRPG, "TECHNICOLOR_SRP_G")
+
+	if nHex != "" || gHex != "" {
+		if nHex == "" || gHex == "" {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("srp_n"),
+				"Invalid SRP group",
+				"srp_n and srp_g must be set together",
+			)
+			return
+		}
+
+		if !config.SRPGroup.Null {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("srp_group"),
+				"Invalid SRP group",
+				"srp_group conflicts with the custom group of srp_n and srp_g",
+			)
+			return
+		}
+
+		ngType = technicolor.NG_CUSTOM
+
+		if err, _, _ := technicolor.GetNG(ngType, nHex, gHex); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("srp_n"),
+				"Invalid SRP group",
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if scheme == "https" {
+		err, p.router = technicolor.NewTechnicolorRouterWithTLS(host, port, tlsOptions)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid TLS configuration",
+				err.Error(),
+			)
+			return
+		}
+	} else {
+		p.router = technicolor.NewTechnicolorRouter(host, port)
+	}
+	p.router.HashAlgorithm, p.router.NGType, p.router.NHex, p.router.GHex = hashAlgorithm, ngType, nHex, gHex
+	p.router.RequestTimeout = requestTimeout
+	p.router.Retries = retries
+	p.router.RetryBackoff = retryBackoff
+	p.router.RetryMaxBackoff = retryMaxBackoff
+	p.router.MinRequestInterval = minRequestInterval
+
+	// capture the pages of the router, to replay them against the parsers
+	if recordDir := os.Getenv("TECHNICOLOR_RECORD_DIR"); recordDir != "" {
+		log.Printf("[INFO] Recording the exchanges with the router in %s", recordDir)
+		p.router.WrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+			return technicolor.NewRecorder(transport, recordDir)
+		})
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if sessionID != "" {
+		err, _ = p.router.Resume(ctx, sessionID, username, password)
+
+		if err != nil {
+			addRouterError(&resp.Diagnostics, "Unable to login", err)
+			return
+		}
+	} else {
+		err, isAuthenticated := p.router.Login(ctx, username, password)
+
+		if err != nil {
+			addRouterError(&resp.Diagnostics, "Unable to login", err)
+			return
+		}
+
+		if !isAuthenticated {
+			resp.Diagnostics.AddError(
+				"Unable to login",
+				"Username or password is incorrect",
+			)
+			return
+		}
+	}
+
+	openRouters.add(p.router)
+
+	p.configured = true
+}
+
+// openRouters keeps the routers configured by this plugin process, so that
+// their sessions can be closed on exit: the router only allows a few admin
+// sessions at a time and every plan would leave one more open.
+var openRouters routerList
+
+type routerList struct {
+	mu      sync.Mutex
+	routers []*technicolor.TechnicolorRouter
+}
+
+func (l *routerList) add(router *technicolor.TechnicolorRouter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routers = append(l.routers, router)
+}
+
+// Shutdown logs out the sessions opened by the provider, resumed sessions
+// are left open. It is called when the plugin server stops, the logouts run
+// concurrently so that a router which does not answer does not hold the
+// others until ctx is done.
+func Shutdown(ctx context.Context) (err error) {
+	openRouters.mu.Lock()
+	routers := openRouters.routers
+	openRouters.routers = nil
+	openRouters.mu.Unlock()
+
+	errs := make(chan error, len(routers))
+
+	for _, router := range routers {
+		go func(router *technicolor.TechnicolorRouter) {
+			if !router.OwnsSession() {
+				errs <- nil
+				return
+			}
+
+			if logoutErr := router.Logout(ctx); logoutErr != nil {
+				errs <- fmt.Errorf("unable to logout from %s: %w", router.Address, logoutErr)
+				return
+			}
+			errs <- nil
+		}(router)
+	}
+
+	for range routers {
+		if logoutErr := <-errs; logoutErr != nil {
+			err = logoutErr
+		}
+	}
+	return
+}
+
+func checkForUnknowsInConfig(config *providerData, resp *tfsdk.ConfigureProviderResponse) bool {
+	// every attribute is read in Configure, none of them can wait for apply
+	unknowns := []struct {
+		name    string
+		unknown bool
+	}{
+		{"host", config.Host.Unknown},
+		{"port", config.Port.Unknown},
+		{"scheme", config.Scheme.Unknown},
+		{"username", config.Username.Unknown},
+		{"password", config.Password.Unknown},
+		{"session_id", config.SessionID.Unknown},
+		{"srp_hash", config.SRPHash.Unknown},
+		{"srp_group", config.SRPGroup.Unknown},
+		{"srp_n", config.SRPN.Unknown},
+		{"srp_g", config.SRPG.Unknown},
+		{"ca_certificate", config.CACertificate.Unknown},
+		{"certificate_fingerprint", config.CertificateFingerprint.Unknown},
+		{"insecure_skip_verify", config.InsecureSkipVerify.Unknown},
+		{"request_timeout", config.RequestTimeout.Unknown},
+		{"timeout", config.Timeout.Unknown},
+		{"retries", config.Retries.Unknown},
+		{"retry_backoff", config.RetryBackoff.Unknown},
+		{"retry_max_backoff", config.RetryMaxBackoff.Unknown},
+		{"min_request_interval", config.MinRequestInterval.Unknown},
+	}
+
+	for _, attribute := range unknowns {
+		if attribute.unknown {
+			resp.Diagnostics.AddWarning(
+				"Unable to create client",
+				"Cannot use unknown value as "+attribute.name,
+			)
+			return false
+		}
 	}
 	return true
 }
 
 func (p *provider) GetResources(_ context.Context) (map[string]tfsdk.ResourceType, diag.Diagnostics) {
 	return map[string]tfsdk.ResourceType{
-		// "freenom_dns_record": resourceFreenomDnsRecordType{},
+		"technicolor_port_forwarding": resourcePortForwardingType{},
 	}, nil
 }
 
 func (p *provider) GetDataSources(_ context.Context) (map[string]tfsdk.DataSourceType, diag.Diagnostics) {
 	return map[string]tfsdk.DataSourceType{
+		"technicolor_port_forwarded":      datasourcePortForwardedType{},
 		"technicolor_port_forwarded_list": datasourcePortForwardedListType{},
+		"technicolor_router_info":         datasourceRouterInfoType{},
 	}, nil
 }
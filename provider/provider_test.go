@@ -0,0 +1,359 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"terraform-provider-technicolor/technicolor"
+	"terraform-provider-technicolor/technicolortest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+const (
+	testAccUsername = "admin"
+	testAccPassword = "secret"
+)
+
+// testAccProtoV6ProviderFactories serves the real provider in process,
+// the acceptance tests point it to a technicolortest.Gateway
+var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"technicolor": providerserver.NewProtocol6WithError(New("test")()),
+}
+
+func testAccGateway(t *testing.T) *technicolortest.Gateway {
+	gateway := technicolortest.NewGateway(testAccUsername, testAccPassword)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func testAccProviderConfig(gateway *technicolortest.Gateway) string {
+	return testAccProviderConfigWithPassword(gateway, testAccPassword)
+}
+
+func testAccProviderConfigWithPassword(gateway *technicolortest.Gateway, password string) string {
+	return fmt.Sprintf(`
+provider "technicolor" {
+  host     = %q
+  port     = %d
+  username = %q
+  password = %q
+}
+`, gateway.Host(), gateway.Port(), testAccUsername, password)
+}
+
+func TestAccProviderConfigure(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded_list" "all" {}
+`,
+				Check: resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+		},
+	})
+}
+
+func TestAccProviderConfigureWrongPassword(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfigWithPassword(gateway, "wrong") + `
+data "technicolor_port_forwarded_list" "all" {}
+`,
+				ExpectError: regexp.MustCompile(`Unable to login`),
+			},
+		},
+	})
+}
+
+func TestAccProviderConfigureTimeout(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.SetLatency(time.Second)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "technicolor" {
+  host            = %q
+  port            = %d
+  username        = %q
+  password        = %q
+  request_timeout = "100ms"
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword),
+				ExpectError: regexp.MustCompile(`(?s)Unable to login.*The\s+router\s+did\s+not\s+answer\s+in\s+time`),
+			},
+		},
+	})
+}
+
+func TestAccProviderShutdown(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_port_forwarded_list" "all" {}
+`,
+			},
+		},
+	})
+
+	if gateway.Sessions() == 0 {
+		t.Fatal("expected the provider to leave its sessions open until shutdown")
+	}
+
+	// the routers of the previous tests are gone already, only the sessions matter
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = Shutdown(ctx)
+
+	if sessions := gateway.Sessions(); sessions != 0 {
+		t.Errorf("expected every session to be logged out, %d still open", sessions)
+	}
+}
+
+func TestAccProviderSessionReuse(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	owner := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+	if err, _ := owner.Login(context.Background(), testAccUsername, testAccPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "technicolor" {
+  host       = %q
+  port       = %d
+  username   = %q
+  password   = %q
+  session_id = %q
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword, owner.SessionID()),
+				Check: resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+		},
+	})
+
+	if logins := gateway.Logins(); logins != 1 {
+		t.Errorf("expected the provider to reuse the session, got %d logins", logins)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = Shutdown(ctx)
+
+	if sessions := gateway.Sessions(); sessions != 1 {
+		t.Errorf("expected the reused session to stay open, got %d sessions", sessions)
+	}
+}
+
+func testAccProviderConfigTLS(gateway *technicolortest.Gateway, tlsAttributes string) string {
+	return fmt.Sprintf(`
+provider "technicolor" {
+  host     = %q
+  port     = %d
+  username = %q
+  password = %q
+  scheme   = "https"
+%s
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword, tlsAttributes)
+}
+
+func TestAccProviderTLS(t *testing.T) {
+	gateway := technicolortest.NewTLSGateway(testAccUsername, testAccPassword)
+	t.Cleanup(gateway.Close)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProviderConfigTLS(gateway, ""),
+				ExpectError: regexp.MustCompile(`not\s+signed\s+by\s+a\s+trusted\s+CA`),
+			},
+			{
+				Config:      testAccProviderConfigTLS(gateway, fmt.Sprintf("certificate_fingerprint = %q\n  insecure_skip_verify = true", gateway.CertificateFingerprint())),
+				ExpectError: regexp.MustCompile(`Only\s+one\s+of\s+certificate_fingerprint,\s+insecure_skip_verify\s+can\s+be\s+set`),
+			},
+			{
+				Config:      testAccProviderConfigTLS(gateway, `certificate_fingerprint = "00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF:00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF"`),
+				ExpectError: regexp.MustCompile(`does not match the pinned\s+fingerprint`),
+			},
+			{
+				Config: testAccProviderConfigTLS(gateway, fmt.Sprintf(`certificate_fingerprint = %q`, gateway.CertificateFingerprint())),
+				Check:  resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+			{
+				Config: testAccProviderConfigTLS(gateway, fmt.Sprintf("ca_certificate = <<EOT\n%sEOT", gateway.CertificatePEM())),
+				Check:  resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+			{
+				Config: testAccProviderConfigTLS(gateway, `insecure_skip_verify = true`),
+				Check:  resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+		},
+	})
+}
+
+func TestAccProviderTLSOptionsWithoutHTTPS(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "technicolor" {
+  host                 = %q
+  port                 = %d
+  username             = %q
+  password             = %q
+  insecure_skip_verify = true
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword),
+				ExpectError: regexp.MustCompile(`require\s+the\s+https\s+scheme`),
+			},
+		},
+	})
+}
+
+func TestCheckForUnknowsInConfig(t *testing.T) {
+	config := providerData{SessionID: types.String{Unknown: true}}
+	var resp tfsdk.ConfigureProviderResponse
+
+	if checkForUnknowsInConfig(&config, &resp) {
+		t.Fatal("an unknown session_id must stop the configuration")
+	}
+
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Detail() != "Cannot use unknown value as session_id" {
+		t.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+
+	if !checkForUnknowsInConfig(&providerData{}, &tfsdk.ConfigureProviderResponse{}) {
+		t.Fatal("a config without unknown values must be accepted")
+	}
+}
+
+func testAccProviderConfigRetries(gateway *technicolortest.Gateway, retries int) string {
+	return fmt.Sprintf(`
+provider "technicolor" {
+  host          = %q
+  port          = %d
+  username      = %q
+  password      = %q
+  retries       = %d
+  retry_backoff = "1ms"
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword, retries)
+}
+
+func TestAccProviderRetries(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				PreConfig: func() {
+					gateway.Fail(http.MethodGet, 2, technicolortest.FAIL_BEFORE_HANDLING)
+				},
+				Config: testAccProviderConfigRetries(gateway, 2),
+				Check:  resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+			{
+				PreConfig: func() {
+					gateway.Fail(http.MethodGet, 1, technicolortest.FAIL_BEFORE_HANDLING)
+				},
+				Config:      testAccProviderConfigRetries(gateway, 0),
+				ExpectError: regexp.MustCompile(`status code error: 500`),
+			},
+		},
+	})
+}
+
+func testAccProviderConfigSRP(gateway *technicolortest.Gateway, srpAttributes string) string {
+	return fmt.Sprintf(`
+provider "technicolor" {
+  host     = %q
+  port     = %d
+  username = %q
+  password = %q
+%s
+}
+
+data "technicolor_port_forwarded_list" "all" {}
+`, gateway.Host(), gateway.Port(), testAccUsername, testAccPassword, srpAttributes)
+}
+
+func TestAccProviderSRPParameters(t *testing.T) {
+	gateway := testAccGateway(t)
+	gateway.HashAlgorithm = technicolor.SHA1
+	gateway.NGType = technicolor.NG_1024
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProviderConfigSRP(gateway, ""),
+				ExpectError: regexp.MustCompile(`Unable to login`),
+			},
+			{
+				Config:      testAccProviderConfigSRP(gateway, `srp_group = 3072`),
+				ExpectError: regexp.MustCompile(`Invalid SRP group`),
+			},
+			{
+				Config:      testAccProviderConfigSRP(gateway, `srp_hash = "md5"`),
+				ExpectError: regexp.MustCompile(`Invalid SRP hash`),
+			},
+			{
+				Config: testAccProviderConfigSRP(gateway, `
+  srp_hash  = "sha1"
+  srp_group = 1024
+`),
+				Check: resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+			{
+				Config: testAccProviderConfigSRP(gateway, fmt.Sprintf(`
+  srp_hash = "sha1"
+  srp_n    = %q
+  srp_g    = "2"
+`, technicolor.N_CONSTANTS[technicolor.NG_1024].Text(16))),
+				Check: resource.TestCheckResourceAttr("data.technicolor_port_forwarded_list.all", "ports.#", "0"),
+			},
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type datasourceRouterInfoType struct{}
+
+func (c datasourceRouterInfoType) GetSchema(_ context.Context) (tfsdk.Schema,
+	diag.Diagnostics) {
+	return tfsdk.Schema{
+		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Type:        types.StringType,
+				Description: "The serial number of the router",
+				Computed:    true,
+			},
+			"product_name": {
+				Type:        types.StringType,
+				Description: "The model of the router, e.g. DGA4132",
+				Computed:    true,
+			},
+			"hardware_version": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+			"firmware_version": {
+				Type:        types.StringType,
+				Description: "The firmware version, it changes when the ISP pushes an upgrade",
+				Computed:    true,
+			},
+			"serial_number": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+			"mac_address": {
+				Type:     types.StringType,
+				Computed: true,
+			},
+			"uptime": {
+				Type:        types.Int64Type,
+				Description: "The seconds since the last boot",
+				Computed:    true,
+			},
+		},
+	}, nil
+}
+
+func (c datasourceRouterInfoType) NewDataSource(_ context.Context,
+	p tfsdk.Provider) (tfsdk.DataSource, diag.Diagnostics) {
+	return datasourceRouterInfo{
+		p: *(p.(*provider)),
+	}, nil
+}
+
+type datasourceRouterInfo struct {
+	p provider
+}
+
+func (r datasourceRouterInfo) Read(ctx context.Context, req tfsdk.ReadDataSourceRequest, resp *tfsdk.ReadDataSourceResponse) {
+	if !r.p.configured {
+		resp.Diagnostics.AddError(
+			"Provider not configured",
+			"The provider hasn't been configured before apply, likely because it depends on an unknown value from another resource. This leads to weird stuff happening, so we'd prefer if you didn't do that. Thanks!",
+		)
+		return
+	}
+
+	ctx, cancel := r.p.withTimeout(ctx)
+	defer cancel()
+
+	var err, info = r.p.router.GetRouterInfo(ctx)
+
+	if err != nil {
+		addRouterError(&resp.Diagnostics, "Failed to get router info", err)
+		return
+	}
+
+	state := routerInfoToModel(info)
+
+	diags := resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
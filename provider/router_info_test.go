@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceRouterInfo(t *testing.T) {
+	gateway := testAccGateway(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(gateway) + `
+data "technicolor_router_info" "router" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "id", "CP1234SA0AB"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "product_name", "DGA4132"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "hardware_version", "VBNT-S"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "firmware_version", "2.2.1"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "serial_number", "CP1234SA0AB"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "mac_address", "D4:35:1D:00:00:01"),
+					resource.TestCheckResourceAttr("data.technicolor_router_info.router", "uptime", "93787"),
+				),
+			},
+		},
+	})
+}
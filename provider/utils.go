@@ -2,9 +2,113 @@ package provider
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"terraform-provider-technicolor/technicolor"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 func computeID(name string, wanPort int, protocol string) string {
 	return fmt.Sprintf("%s/%s/%d", strings.ToLower(name), protocol, wanPort)
 }
+
+// parseID is the inverse of computeID, the name is split from the right
+// since it may itself contain slashes
+func parseID(id string) (identity technicolor.PortForwardedIdentity, err error) {
+	lastSlash := strings.LastIndex(id, "/")
+	if lastSlash < 0 {
+		return identity, fmt.Errorf("invalid id %q, expected name/protocol/wanport", id)
+	}
+
+	identity.WanPortStart, err = strconv.Atoi(id[lastSlash+1:])
+	if err != nil {
+		return identity, fmt.Errorf("invalid wan port in id %q: %w", id, err)
+	}
+
+	protocolSlash := strings.LastIndex(id[:lastSlash], "/")
+	if protocolSlash < 0 {
+		return identity, fmt.Errorf("invalid id %q, expected name/protocol/wanport", id)
+	}
+
+	identity.Name = id[:protocolSlash]
+	identity.Protocol = id[protocolSlash+1 : lastSlash]
+	return identity, nil
+}
+
+// getStringConfig reads a string attribute, falling back to the
+// environment variable
+func getStringConfig(value types.String, envName string) string {
+	if value.Null {
+		return os.Getenv(envName)
+	}
+	return value.Value
+}
+
+// getBoolConfig reads a bool attribute, falling back to the environment
+// variable and then to false
+func getBoolConfig(value types.Bool, envName string) (bool, error) {
+	if !value.Null {
+		return value.Value, nil
+	}
+
+	boolString := os.Getenv(envName)
+	if boolString == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(boolString)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q in %s", boolString, envName)
+	}
+	return b, nil
+}
+
+// getIntConfig reads a non negative number attribute, falling back to the
+// environment variable and then to the default value
+func getIntConfig(value types.Int64, envName string, defaultValue int) (int, error) {
+	if !value.Null {
+		if value.Value < 0 {
+			return 0, fmt.Errorf("invalid number %d: must not be negative", value.Value)
+		}
+		return int(value.Value), nil
+	}
+
+	intString := os.Getenv(envName)
+	if intString == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(intString)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q in %s", intString, envName)
+	}
+	return n, nil
+}
+
+// getDurationConfig reads a duration attribute, falling back to the
+// environment variable and then to the default value
+func getDurationConfig(value types.String, envName string, defaultValue time.Duration) (time.Duration, error) {
+	durationString := value.Value
+
+	if value.Null {
+		durationString = os.Getenv(envName)
+	}
+
+	if durationString == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(durationString)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", durationString, err)
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q: must not be negative", durationString)
+	}
+
+	return duration, nil
+}
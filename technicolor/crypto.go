@@ -4,10 +4,10 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
-	"log"
+	"fmt"
+	"io"
 	"math/big"
-	"math/rand"
-	"time"
+	"strings"
 )
 
 func bigIntFromString(s string) *big.Int {
@@ -58,20 +58,87 @@ var G_CONSTANTS = map[int]int64{
 	8192: 0x13,
 }
 
+var HASH_ALGORITHMS = map[string]int{
+	"sha1":   SHA1,
+	"sha224": SHA224,
+	"sha256": SHA256,
+	"sha384": SHA384,
+	"sha512": SHA512,
+}
+
+// MIN_CUSTOM_N_BITS is the size of the smallest standard group, custom
+// groups cannot be weaker
+const MIN_CUSTOM_N_BITS = 1024
+
+// the Miller-Rabin rounds of the primality checks of a custom N
+const SAFE_PRIME_ROUNDS = 20
+
 // def GetNG(ng_type, n_hex, g_hex):
 //     if ng_type < NG_CUSTOM:
 //         n_hex, g_hex = _ng_const[ng_type]
 //     return int(n_hex, 16), int(g_hex, 16)
 
-// func GetNG(ng_type int, n_hex *big.Int, g_hex int) (*big.Int, int) {
-func GetNG(ng_type int) (n_hex *big.Int, g_hex int64) {
-	if ng_type != NG_CUSTOM {
-		n_hex = N_CONSTANTS[ng_type]
-		g_hex = G_CONSTANTS[ng_type]
-	} else {
-		log.Fatalf("ng_type %v not implemented yet", ng_type)
+// GetNG returns N and g of the group, nHex and gHex are only used with
+// NG_CUSTOM
+func GetNG(ng_type int, nHex string, gHex string) (err error, n_hex *big.Int, g_hex int64) {
+	if ng_type == NG_CUSTOM {
+		return getCustomNG(nHex, gHex)
+	}
+
+	n_hex, ok := N_CONSTANTS[ng_type]
+
+	if !ok {
+		return fmt.Errorf("%w: unknown group %d", ErrInvalidSRPParameters, ng_type), nil, 0
 	}
-	return
+
+	return nil, n_hex, G_CONSTANTS[ng_type]
+}
+
+func getCustomNG(nHex string, gHex string) (err error, N *big.Int, g int64) {
+	N, ok := new(big.Int).SetString(strings.TrimPrefix(nHex, "0x"), 16)
+
+	if !ok {
+		return fmt.Errorf("%w: N is not an hex number", ErrInvalidSRPParameters), nil, 0
+	}
+
+	if N.BitLen() < MIN_CUSTOM_N_BITS || N.Bit(0) == 0 {
+		return fmt.Errorf("%w: N must be an odd prime of at least %d bits", ErrInvalidSRPParameters, MIN_CUSTOM_N_BITS), nil, 0
+	}
+
+	// SRP needs a safe prime N = 2q+1 with q prime, like the RFC 5054 groups
+	q := new(big.Int).Rsh(N, 1)
+	if !N.ProbablyPrime(SAFE_PRIME_ROUNDS) || !q.ProbablyPrime(SAFE_PRIME_ROUNDS) {
+		return fmt.Errorf("%w: N must be a safe prime, (N-1)/2 must be prime too", ErrInvalidSRPParameters), nil, 0
+	}
+
+	G, ok := new(big.Int).SetString(strings.TrimPrefix(gHex, "0x"), 16)
+
+	if !ok {
+		return fmt.Errorf("%w: g is not an hex number", ErrInvalidSRPParameters), nil, 0
+	}
+
+	if !G.IsInt64() || G.Int64() < 2 || G.Cmp(N) >= 0 {
+		return fmt.Errorf("%w: g must be a small generator greater than 1", ErrInvalidSRPParameters), nil, 0
+	}
+
+	return nil, N, G.Int64()
+}
+
+// ComputeK derives the multiplier k = H(N | PAD(g)) of SRP-6a, where g is
+// padded to the length of N
+func ComputeK(hashAlgorithm int, N *big.Int, g int64) (err error, k *big.Int) {
+	NBytes := LongToBytes(N)
+	gBytes := LongToBytes(big.NewInt(g))
+
+	padded := make([]byte, len(NBytes)-len(gBytes), len(NBytes))
+	padded = append(padded, gBytes...)
+
+	hash := ComputeSha(append(NBytes, padded...), hashAlgorithm)
+
+	if hash == nil {
+		return fmt.Errorf("%w: unknown hash algorithm %d", ErrInvalidSRPParameters, hashAlgorithm), nil
+	}
+	return nil, BytesToLong(hash)
 }
 
 func BytesToLong(bytes []byte) (n *big.Int) {
@@ -109,28 +176,49 @@ func LongToBytes(n *big.Int) (bytes []byte) {
 	return bytes
 }
 
-func GenerateRandomBigInt(bytesSize int) (n big.Int) {
-	// rand.Seed(time.Now().UnixNano())
-	randomGenerator := rand.New(rand.NewSource(time.Now().UnixNano()))
+// MIN_EPHEMERAL_SIZE is the smallest accepted size in bytes of the private
+// ephemerals a and b
+const MIN_EPHEMERAL_SIZE = 32
 
-	n.SetInt64(0)
-	for i := 0; i < bytesSize; i++ {
-		n.Lsh(&n, 8)
-		n.Or(&n, big.NewInt(int64(randomGenerator.Intn(256))))
+// EphemeralSize returns the size in bytes of the private ephemerals for the
+// group N. 0 means the size of N itself, the safe choice whatever the group;
+// a smaller size is faster but must be at least MIN_EPHEMERAL_SIZE.
+func EphemeralSize(N *big.Int, size int) (err error, bytesSize int) {
+	if size == 0 {
+		return nil, (N.BitLen() + 7) / 8
 	}
-	return n
+
+	if size < MIN_EPHEMERAL_SIZE {
+		return fmt.Errorf("%w: ephemeral size %d is below %d bytes", ErrInvalidSRPParameters, size, MIN_EPHEMERAL_SIZE), 0
+	}
+	return nil, size
+}
+
+// GenerateRandomBigInt reads bytesSize bytes from random, which is
+// crypto/rand.Reader unless a test needs a deterministic source
+func GenerateRandomBigInt(random io.Reader, bytesSize int) (err error, n *big.Int) {
+	buffer := make([]byte, bytesSize)
+
+	if _, err = io.ReadFull(random, buffer); err != nil {
+		return fmt.Errorf("unable to generate random number: %w", err), nil
+	}
+	return nil, new(big.Int).SetBytes(buffer)
 }
 
-func GenerateRandomBigIntFirstBit(bytesSize int) *big.Int {
+func GenerateRandomBigIntFirstBit(random io.Reader, bytesSize int) (err error, n *big.Int) {
 	if bytesSize == 0 {
-		return big.NewInt(0)
+		return nil, big.NewInt(0)
 	}
 
 	offset := bytesSize*8 - 1
-	n := GenerateRandomBigInt(bytesSize)
+	err, n = GenerateRandomBigInt(random, bytesSize)
+
+	if err != nil {
+		return err, nil
+	}
 	// set first bit to 1
 	// get_random(nbytes) | (1 << offset)
-	return new(big.Int).Or(&n, new(big.Int).Lsh(big.NewInt(1), uint(offset)))
+	return nil, n.Or(n, new(big.Int).Lsh(big.NewInt(1), uint(offset)))
 }
 
 func ComputeSha(content []byte, hashAlgorithm int) []byte {
@@ -158,20 +246,23 @@ func ComputeSha(content []byte, hashAlgorithm int) []byte {
 	return nil
 }
 
-func ComputeXorHashNG(hashAlgorithm int, N *big.Int, g int64) []byte {
+func ComputeXorHashNG(hashAlgorithm int, N *big.Int, g int64) (err error, hashNG []byte) {
 	hashN := ComputeSha(LongToBytes(N), hashAlgorithm)
 	hashG := ComputeSha(LongToBytes(big.NewInt(g)), hashAlgorithm)
 
+	if hashN == nil || hashG == nil {
+		return fmt.Errorf("%w: unknown hash algorithm %d", ErrInvalidSRPParameters, hashAlgorithm), nil
+	}
+
 	// assert len(hashN) == len(hashG)
 	if len(hashN) != len(hashG) {
-		log.Fatalf("len(hashN): %v != len(hashG): %v", len(hashN), len(hashG))
-		return nil
+		return fmt.Errorf("%w: len(hashN): %v != len(hashG): %v", ErrInvalidSRPParameters, len(hashN), len(hashG)), nil
 	}
 
-	hashNG := make([]byte, len(hashN))
+	hashNG = make([]byte, len(hashN))
 	for i := range hashN {
 		hashNG[i] = hashN[i] ^ hashG[i] // xor
 	}
 
-	return hashNG
+	return nil, hashNG
 }
@@ -2,7 +2,9 @@ package technicolor
 
 import (
 	"bytes"
-	"log"
+	"crypto/rand"
+	"fmt"
+	"io"
 	"math/big"
 )
 
@@ -22,6 +24,7 @@ type CryptoUserInternal struct {
 	a       *big.Int
 	A       *big.Int
 	k       *big.Int
+	hashNG  []byte
 	M       []byte
 	HashAMK []byte
 }
@@ -30,17 +33,50 @@ type CryptoUserState struct {
 	Authenticated bool
 }
 
-func NewCryptoUser(username string, password string, hashAlgorithm int, ngType int) *CryptoUser {
-	a := GenerateRandomBigIntFirstBit(32)
-	return NewCryptoUserWithA(username, password, hashAlgorithm, ngType, a)
+func NewCryptoUser(username string, password string, hashAlgorithm int, ngType int, nHex string, gHex string) (err error, user *CryptoUser) {
+	return NewCryptoUserWithRandom(username, password, hashAlgorithm, ngType, nHex, gHex, rand.Reader, 0)
 }
 
-func NewCryptoUserWithA(username string, password string, hashAlgorithm int, ngType int, a *big.Int) *CryptoUser {
+// NewCryptoUserWithRandom draws the private ephemeral a from random, with
+// the size in bytes given to EphemeralSize
+func NewCryptoUserWithRandom(username string, password string, hashAlgorithm int, ngType int, nHex string, gHex string, random io.Reader, ephemeralSize int) (err error, user *CryptoUser) {
+	err, N, _ := GetNG(ngType, nHex, gHex)
 
-	N, g := GetNG(ngType)
+	if err != nil {
+		return err, nil
+	}
+
+	err, ephemeralSize = EphemeralSize(N, ephemeralSize)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err, a := GenerateRandomBigIntFirstBit(random, ephemeralSize)
+
+	if err != nil {
+		return err, nil
+	}
+	return NewCryptoUserWithA(username, password, hashAlgorithm, ngType, nHex, gHex, a)
+}
+
+func NewCryptoUserWithA(username string, password string, hashAlgorithm int, ngType int, nHex string, gHex string, a *big.Int) (err error, user *CryptoUser) {
+
+	err, N, g := GetNG(ngType, nHex, gHex)
+
+	if err != nil {
+		return err, nil
+	}
 
 	if N == nil || g == 0 {
-		log.Fatalf("Invalid N(%v) or g(%v)", N, g)
+		return fmt.Errorf("%w: invalid N(%v) or g(%v)", ErrInvalidSRPParameters, N, g), nil
+	}
+
+	// H(N) xor H(g) only depends on the parameters, it also validates the hash
+	err, hashNG := ComputeXorHashNG(hashAlgorithm, N, g)
+
+	if err != nil {
+		return err, nil
 	}
 
 	// a := GenerateRandomBigIntFirstBit(32)
@@ -48,20 +84,24 @@ func NewCryptoUserWithA(username string, password string, hashAlgorithm int, ngT
 	// // A = ModPow(g, a, N)
 	A := new(big.Int).Exp(big.NewInt(int64(g)), a, N)
 
-	// // k = int("05b9e8ef059c6b32ea59fc1d322d37f04aa30bae5aa9003b8321e21ddb04e300", 16)
-	k := bigIntFromStringHex("05b9e8ef059c6b32ea59fc1d322d37f04aa30bae5aa9003b8321e21ddb04e300")
+	err, k := ComputeK(hashAlgorithm, N, g)
+
+	if err != nil {
+		return err, nil
+	}
 
-	return &CryptoUser{
+	return nil, &CryptoUser{
 		Username:      username,
 		Password:      password,
 		HashAlgorithm: hashAlgorithm,
 		NGType:        ngType,
 		internal: &CryptoUserInternal{
-			N: N,
-			g: g,
-			A: A,
-			k: k,
-			a: a,
+			N:      N,
+			g:      g,
+			A:      A,
+			k:      k,
+			a:      a,
+			hashNG: hashNG,
 		},
 		state: &CryptoUserState{
 			Authenticated: false,
@@ -91,7 +131,7 @@ func (c *CryptoUser) ComputeHashAMK(K []byte) []byte {
 
 func (c *CryptoUser) ComputeM(sChallenge []byte, BChallenge []byte, K []byte) []byte {
 	return c.ComputeSha(
-		ComputeXorHashNG(c.HashAlgorithm, c.internal.N, c.internal.g),
+		c.internal.hashNG,
 		c.ComputeSha([]byte(c.Username)),
 		sChallenge,
 		LongToBytes(c.internal.A),
@@ -0,0 +1,58 @@
+package technicolor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestEphemeralDefaultSize(t *testing.T) {
+	for _, ngType := range ngTypes {
+		err, user := NewCryptoUser("admin", "secret", SHA256, ngType, "", "")
+		require.NoError(t, err)
+
+		err, N, _ := GetNG(ngType, "", "")
+		require.NoError(t, err)
+
+		// the first bit is always set, a is exactly as large as N
+		assert.Equal(t, (N.BitLen()+7)/8*8, user.internal.a.BitLen())
+	}
+}
+
+func TestEphemeralFromRandom(t *testing.T) {
+	random := bytes.Repeat([]byte{0x42}, MIN_EPHEMERAL_SIZE)
+
+	err, user := NewCryptoUserWithRandom("admin", "secret", SHA256, NG_2048, "", "", bytes.NewReader(random), MIN_EPHEMERAL_SIZE)
+	require.NoError(t, err)
+
+	err, a := GenerateRandomBigIntFirstBit(bytes.NewReader(random), MIN_EPHEMERAL_SIZE)
+	require.NoError(t, err)
+
+	err, expected := NewCryptoUserWithA("admin", "secret", SHA256, NG_2048, "", "", a)
+	require.NoError(t, err)
+
+	_, A := user.StartAuthentication()
+	_, expectedA := expected.StartAuthentication()
+	assert.Equal(t, expectedA, A)
+}
+
+func TestEphemeralInvalid(t *testing.T) {
+	err, _ := NewCryptoUserWithRandom("admin", "secret", SHA256, NG_2048, "", "", bytes.NewReader(make([]byte, 16)), 16)
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+
+	err, _ = NewCryptoUserWithRandom("admin", "secret", SHA256, NG_2048, "", "", failingReader{}, 0)
+	assert.Error(t, err)
+
+	// a short read must not silently produce a smaller ephemeral
+	err, _ = NewCryptoUserWithRandom("admin", "secret", SHA256, NG_2048, "", "", bytes.NewReader(make([]byte, MIN_EPHEMERAL_SIZE-1)), MIN_EPHEMERAL_SIZE)
+	assert.Error(t, err)
+}
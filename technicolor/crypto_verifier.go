@@ -0,0 +1,224 @@
+package technicolor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// CryptoVerifier is the host side of the SRP-6a handshake started by
+// CryptoUser
+type CryptoVerifier struct {
+	Username      string
+	HashAlgorithm int
+	NGType        int
+	internal      *CryptoVerifierInternal
+	state         *CryptoVerifierState
+}
+
+type CryptoVerifierInternal struct {
+	N       *big.Int
+	g       int64
+	k       *big.Int
+	s       []byte
+	v       *big.Int
+	A       *big.Int
+	b       *big.Int
+	B       *big.Int
+	K       []byte
+	M       []byte
+	HashAMK []byte
+}
+
+type CryptoVerifierState struct {
+	Authenticated bool
+	SafetyFailed  bool
+}
+
+// CreateSaltedVerificationKey generates the salt and the verifier the host
+// stores in place of the password
+func CreateSaltedVerificationKey(username string, password string, hashAlgorithm int, ngType int, nHex string, gHex string) (err error, salt []byte, verifier []byte) {
+	err, saltInt := GenerateRandomBigIntFirstBit(rand.Reader, 4)
+
+	if err != nil {
+		return err, nil, nil
+	}
+
+	salt = LongToBytes(saltInt)
+	err, verifier = CreateVerificationKey(username, password, salt, hashAlgorithm, ngType, nHex, gHex)
+	return err, salt, verifier
+}
+
+// CreateVerificationKey computes v = g^x with the given salt
+func CreateVerificationKey(username string, password string, salt []byte, hashAlgorithm int, ngType int, nHex string, gHex string) (err error, verifier []byte) {
+	err, N, g := GetNG(ngType, nHex, gHex)
+
+	if err != nil {
+		return err, nil
+	}
+
+	if ComputeSha(nil, hashAlgorithm) == nil {
+		return fmt.Errorf("%w: unknown hash algorithm %d", ErrInvalidSRPParameters, hashAlgorithm), nil
+	}
+
+	user := &CryptoUser{
+		Username:      username,
+		Password:      password,
+		HashAlgorithm: hashAlgorithm,
+	}
+	x := BytesToLong(user.ComputeX(salt))
+
+	return nil, LongToBytes(new(big.Int).Exp(big.NewInt(g), x, N))
+}
+
+func NewCryptoVerifier(username string, salt []byte, verifier []byte, ABytes []byte, hashAlgorithm int, ngType int, nHex string, gHex string) (err error, host *CryptoVerifier) {
+	return NewCryptoVerifierWithRandom(username, salt, verifier, ABytes, hashAlgorithm, ngType, nHex, gHex, rand.Reader, 0)
+}
+
+// NewCryptoVerifierWithRandom draws the private ephemeral b from random,
+// with the size in bytes given to EphemeralSize
+func NewCryptoVerifierWithRandom(username string, salt []byte, verifier []byte, ABytes []byte, hashAlgorithm int, ngType int, nHex string, gHex string, random io.Reader, ephemeralSize int) (err error, host *CryptoVerifier) {
+	err, N, _ := GetNG(ngType, nHex, gHex)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err, ephemeralSize = EphemeralSize(N, ephemeralSize)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err, b := GenerateRandomBigIntFirstBit(random, ephemeralSize)
+
+	if err != nil {
+		return err, nil
+	}
+	return NewCryptoVerifierWithB(username, salt, verifier, ABytes, hashAlgorithm, ngType, nHex, gHex, b)
+}
+
+func NewCryptoVerifierWithB(username string, salt []byte, verifier []byte, ABytes []byte, hashAlgorithm int, ngType int, nHex string, gHex string, b *big.Int) (err error, host *CryptoVerifier) {
+	err, N, g := GetNG(ngType, nHex, gHex)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err, hashNG := ComputeXorHashNG(hashAlgorithm, N, g)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err, k := ComputeK(hashAlgorithm, N, g)
+
+	if err != nil {
+		return err, nil
+	}
+
+	verifierUser := &CryptoVerifier{
+		Username:      username,
+		HashAlgorithm: hashAlgorithm,
+		NGType:        ngType,
+		internal: &CryptoVerifierInternal{
+			N: N,
+			g: g,
+			k: k,
+			s: salt,
+			v: BytesToLong(verifier),
+			A: BytesToLong(ABytes),
+			b: b,
+		},
+		state: &CryptoVerifierState{},
+	}
+
+	// SRP-6a safety check
+	if new(big.Int).Mod(verifierUser.internal.A, N).Cmp(big.NewInt(0)) == 0 {
+		verifierUser.state.SafetyFailed = true
+		return nil, verifierUser
+	}
+
+	// B = (k * v + g^b) % N
+	verifierUser.internal.B = new(big.Int).Mod(
+		new(big.Int).Add(
+			new(big.Int).Mul(
+				verifierUser.internal.k,
+				verifierUser.internal.v,
+			),
+			new(big.Int).Exp(big.NewInt(g), b, N),
+		),
+		N,
+	)
+
+	u := BytesToLong(verifierUser.ComputeSha(
+		LongToBytes(verifierUser.internal.A),
+		LongToBytes(verifierUser.internal.B),
+	))
+
+	// S = pow(A * pow(v, u, N), b, N)
+	S := new(big.Int).Exp(
+		new(big.Int).Mul(
+			verifierUser.internal.A,
+			new(big.Int).Exp(verifierUser.internal.v, u, N),
+		),
+		b,
+		N,
+	)
+
+	verifierUser.internal.K = verifierUser.ComputeSha(LongToBytes(S))
+
+	verifierUser.internal.M = verifierUser.ComputeSha(
+		hashNG,
+		verifierUser.ComputeSha([]byte(username)),
+		salt,
+		LongToBytes(verifierUser.internal.A),
+		LongToBytes(verifierUser.internal.B),
+		verifierUser.internal.K,
+	)
+
+	verifierUser.internal.HashAMK = verifierUser.ComputeSha(
+		LongToBytes(verifierUser.internal.A),
+		verifierUser.internal.M,
+		verifierUser.internal.K,
+	)
+
+	return nil, verifierUser
+}
+
+func (v *CryptoVerifier) ComputeSha(content ...[]byte) []byte {
+	return ComputeSha(bytes.Join(content, []byte{}), v.HashAlgorithm)
+}
+
+// GetChallenge returns the salt and B to send to the user,
+// both are nil when A failed the SRP-6a safety check
+func (v *CryptoVerifier) GetChallenge() ([]byte, []byte) {
+	if v.state.SafetyFailed {
+		return nil, nil
+	}
+	return v.internal.s, LongToBytes(v.internal.B)
+}
+
+// VerifySession checks the proof M of the user and returns the host proof
+// HAMK, or nil if the user did not prove to know the password
+func (v *CryptoVerifier) VerifySession(userM []byte) []byte {
+	if v.state.SafetyFailed {
+		return nil
+	}
+
+	if bytes.Equal(v.internal.M, userM) {
+		v.state.Authenticated = true
+		return v.internal.HashAMK
+	}
+	return nil
+}
+
+func (v *CryptoVerifier) IsAuthenticated() bool {
+	return v.state.Authenticated
+}
+
+func (v *CryptoVerifier) GetSessionKey() []byte {
+	return v.internal.K
+}
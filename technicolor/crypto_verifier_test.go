@@ -0,0 +1,149 @@
+package technicolor
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var hashAlgorithms = []int{SHA1, SHA224, SHA256, SHA384, SHA512}
+
+var ngTypes = []int{NG_1024, NG_2048, NG_4096, NG_8192}
+
+func TestHandshake(t *testing.T) {
+	for _, hashAlgorithm := range hashAlgorithms {
+		for _, ngType := range ngTypes {
+			t.Run(fmt.Sprintf("hash=%d/ng=%d", hashAlgorithm, ngType), func(t *testing.T) {
+				err, salt, verifier := CreateSaltedVerificationKey("admin", "secret", hashAlgorithm, ngType, "", "")
+				require.NoError(t, err)
+
+				err, user := NewCryptoUser("admin", "secret", hashAlgorithm, ngType, "", "")
+				require.NoError(t, err)
+				I, A := user.StartAuthentication()
+
+				err, host := NewCryptoVerifier(I, salt, verifier, A, hashAlgorithm, ngType, "", "")
+				require.NoError(t, err)
+				s, B := host.GetChallenge()
+				require.NotNil(t, s)
+				require.NotNil(t, B)
+
+				M, _ := user.ProcessChallenge(s, B)
+				require.NotNil(t, M)
+
+				HAMK := host.VerifySession(M)
+				require.NotNil(t, HAMK)
+				assert.True(t, host.IsAuthenticated())
+
+				assert.True(t, user.ValidateAuthentication(HAMK))
+			})
+		}
+	}
+}
+
+func TestHandshakeWrongPassword(t *testing.T) {
+	err, salt, verifier := CreateSaltedVerificationKey("admin", "secret", SHA256, NG_2048, "", "")
+	require.NoError(t, err)
+
+	err, user := NewCryptoUser("admin", "wrong", SHA256, NG_2048, "", "")
+	require.NoError(t, err)
+	I, A := user.StartAuthentication()
+
+	err, host := NewCryptoVerifier(I, salt, verifier, A, SHA256, NG_2048, "", "")
+	require.NoError(t, err)
+	s, B := host.GetChallenge()
+
+	M, _ := user.ProcessChallenge(s, B)
+
+	assert.Nil(t, host.VerifySession(M))
+	assert.False(t, host.IsAuthenticated())
+	assert.False(t, user.ValidateAuthentication(nil))
+}
+
+func TestVerifierSafetyCheck(t *testing.T) {
+	err, salt, verifier := CreateSaltedVerificationKey("admin", "secret", SHA256, NG_2048, "", "")
+	require.NoError(t, err)
+
+	err, N, _ := GetNG(NG_2048, "", "")
+	require.NoError(t, err)
+
+	err, host := NewCryptoVerifier("admin", salt, verifier, LongToBytes(N), SHA256, NG_2048, "", "")
+	require.NoError(t, err)
+	s, B := host.GetChallenge()
+
+	assert.Nil(t, s)
+	assert.Nil(t, B)
+	assert.Nil(t, host.VerifySession([]byte{}))
+}
+
+func TestInvalidSRPParameters(t *testing.T) {
+	err, _, _ := GetNG(NG_CUSTOM, "", "")
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+
+	err, _ = NewCryptoUser("admin", "secret", SHA256, 3072, "", "")
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+
+	err, _ = NewCryptoUser("admin", "secret", 42, NG_2048, "", "")
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+
+	err, _, _ = CreateSaltedVerificationKey("admin", "secret", 42, NG_2048, "", "")
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+
+	err, _ = NewCryptoVerifier("admin", []byte{1}, []byte{1}, []byte{1}, SHA256, NG_CUSTOM, "", "")
+	assert.ErrorIs(t, err, ErrInvalidSRPParameters)
+}
+
+func TestComputeK(t *testing.T) {
+	// the multiplier the TIM HUB uses with SHA256 and the 2048 bits group
+	err, k := ComputeK(SHA256, N_CONSTANTS[NG_2048], G_CONSTANTS[NG_2048])
+	require.NoError(t, err)
+	assert.Equal(t, "5b9e8ef059c6b32ea59fc1d322d37f04aa30bae5aa9003b8321e21ddb04e300", k.Text(16))
+}
+
+func TestCustomGroup(t *testing.T) {
+	nHex := N_CONSTANTS[NG_1024].Text(16)
+
+	err, salt, verifier := CreateSaltedVerificationKey("admin", "secret", SHA1, NG_CUSTOM, nHex, "2")
+	require.NoError(t, err)
+
+	err, user := NewCryptoUser("admin", "secret", SHA1, NG_CUSTOM, "0x"+nHex, "0x2")
+	require.NoError(t, err)
+	I, A := user.StartAuthentication()
+
+	// the same group given by type gives the same keys
+	err, host := NewCryptoVerifier(I, salt, verifier, A, SHA1, NG_1024, "", "")
+	require.NoError(t, err)
+	s, B := host.GetChallenge()
+
+	M, _ := user.ProcessChallenge(s, B)
+	assert.True(t, user.ValidateAuthentication(host.VerifySession(M)))
+
+	for _, params := range [][2]string{
+		{"", "2"},
+		{"zz", "2"},
+		{"ffff", "2"},
+		{nHex, ""},
+		{nHex, "1"},
+		{nHex, nHex},
+		// odd but composite
+		{new(big.Int).Mul(N_CONSTANTS[NG_1024], big.NewInt(3)).Text(16), "2"},
+		// prime but not safe
+		{unsafePrime(N_CONSTANTS[NG_1024]).Text(16), "2"},
+	} {
+		err, _, _ := GetNG(NG_CUSTOM, params[0], params[1])
+		assert.ErrorIs(t, err, ErrInvalidSRPParameters, "N=%s g=%s", params[0], params[1])
+	}
+}
+
+// unsafePrime returns the first prime after n for which (p-1)/2 is not prime
+func unsafePrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for {
+		p.Add(p, big.NewInt(2))
+		if p.ProbablyPrime(SAFE_PRIME_ROUNDS) && !new(big.Int).Rsh(p, 1).ProbablyPrime(SAFE_PRIME_ROUNDS) {
+			return p
+		}
+	}
+}
@@ -5,3 +5,10 @@ const TECHNICOLOR_ENDPOINT_AUTHENTICATE = "/authenticate"
 const TECHNICOLOR_ENDPOINT_PORT_FORWARDING = "/modals/wanservices-modal.lp"
 
 const TECHNICOLOR_ENDPOINT_LOGIN = "login.lp?action=lastaccess"
+
+const TECHNICOLOR_ENDPOINT_LOGOUT = "/"
+
+// the cookie of the web UI session
+const SESSION_COOKIE = "sessionID"
+
+const TECHNICOLOR_ENDPOINT_GATEWAY = "/modals/gateway-modal.lp"
@@ -0,0 +1,96 @@
+package technicolor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the router client, they can be matched with errors.Is
+// whatever the detail message
+var (
+	// the router refused the credentials
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// the router answered with the login page, the session is not valid anymore
+	ErrSessionExpired = errors.New("session expired")
+	// the router refused the CSRF token of the request
+	ErrCSRFRejected = errors.New("CSRF token rejected")
+	// the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// the row already exists, or more than one row matches
+	ErrConflict = errors.New("conflict")
+	// the page does not look like expected, likely a different firmware
+	ErrUnexpectedLayout = errors.New("unexpected page layout")
+	// the hash algorithm or the group of the SRP handshake are not supported
+	ErrInvalidSRPParameters = errors.New("invalid SRP parameters")
+	// the router certificate is not the pinned one
+	ErrCertificateMismatch = errors.New("router certificate does not match the pinned fingerprint")
+	// the router refused the values of a form
+	ErrValidation = errors.New("rejected by the router")
+)
+
+var ErrPortForwardedNotFound = fmt.Errorf("port forwarding %w", ErrNotFound)
+
+var ErrPortForwardedAmbiguous = fmt.Errorf("port forwarding is ambiguous: %w", ErrConflict)
+
+var ErrPortForwardedExists = fmt.Errorf("port forwarding already exists: %w", ErrConflict)
+
+// StatusError is returned when the router answers with a status other than 200
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %d %s", e.StatusCode, e.Body)
+}
+
+// Is maps 403, the answer to an invalid CSRF token, to ErrCSRFRejected
+func (e *StatusError) Is(target error) bool {
+	return target == ErrCSRFRejected && e.StatusCode == 403
+}
+
+// LayoutError is returned when an element the client relies on is missing
+// from a page, it matches ErrUnexpectedLayout
+type LayoutError struct {
+	Page    string
+	Element string
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("%s: %s not found in %s", ErrUnexpectedLayout, e.Element, e.Page)
+}
+
+func (e *LayoutError) Unwrap() error {
+	return ErrUnexpectedLayout
+}
+
+// FieldError is a message of the router about a form parameter, Field is
+// empty for the messages about the whole form
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError is returned when the router answers a form with inline
+// error messages instead of applying it, it matches ErrValidation
+type ValidationError struct {
+	Page   string
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, fieldError := range e.Errors {
+		if fieldError.Field == "" {
+			messages = append(messages, fieldError.Message)
+		} else {
+			messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+		}
+	}
+	return fmt.Sprintf("%s: %s in %s", ErrValidation, strings.Join(messages, ", "), e.Page)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,93 @@
+package technicolor
+
+import (
+	"context"
+	"fmt"
+)
+
+// ModalRow is a row of a modal table. It is decoded from the html table
+// with the table struct tags, see ParseTable, and encoded into the form
+// posted by the web UI with FormData.
+type ModalRow interface {
+	FormData() map[string]string
+}
+
+// ModalTable reads and changes a table of a /modals/*.lp page. The web UI
+// posts every change to the page with the id of the table, the action
+// (TABLE-ADD, TABLE-EDIT, TABLE-MODIFY or TABLE-DELETE) and the index of
+// the row, starting from 1.
+// The indexes shift when a row is deleted, so writes to the same table
+// must not run concurrently.
+type ModalTable[R ModalRow] struct {
+	router  *TechnicolorRouter
+	Page    string
+	TableID string
+}
+
+// NewModalTable returns the table with the given id of the modal page,
+// e.g. TECHNICOLOR_ENDPOINT_PORT_FORWARDING and "portforwarding"
+func NewModalTable[R ModalRow](router *TechnicolorRouter, page string, tableID string) *ModalTable[R] {
+	return &ModalTable[R]{
+		router:  router,
+		Page:    page,
+		TableID: tableID,
+	}
+}
+
+// List returns the rows of the table, the row at position i has index i+1
+func (table *ModalTable[R]) List(ctx context.Context) (err error, rows []R) {
+	err, document := table.router.getModalDocument(ctx, table.router.getEndpoint(table.Page))
+
+	if err != nil {
+		return err, nil
+	}
+
+	// the web UI renders each table with its tableid as id
+	err = ParseTable(table.Page, document, "#"+table.TableID, &rows)
+
+	if err != nil {
+		return err, nil
+	}
+	return
+}
+
+// Add appends a row, index is the position of the new row, that is the
+// number of rows plus one
+func (table *ModalTable[R]) Add(ctx context.Context, index int, row R) (err error) {
+	data := row.FormData()
+	for key, value := range table.form("TABLE-ADD", index) {
+		data[key] = value
+	}
+
+	err, _ = table.router.postModalDocument(ctx, table.router.getEndpoint(table.Page), data)
+	return
+}
+
+// Modify replaces the row at index. The web UI first switches the row to
+// edit mode (TABLE-EDIT) and then submits the new values (TABLE-MODIFY),
+// the same is done here.
+func (table *ModalTable[R]) Modify(ctx context.Context, index int, row R) (err error) {
+	data := row.FormData()
+	for key, value := range table.form("TABLE-MODIFY", index) {
+		data[key] = value
+	}
+
+	// the edit mode belongs to the session, both are replayed together
+	err, _ = table.router.postModalDocument(ctx, table.router.getEndpoint(table.Page), table.form("TABLE-EDIT", index), data)
+	return
+}
+
+// Delete removes the row at index, the following rows move up by one
+func (table *ModalTable[R]) Delete(ctx context.Context, index int) (err error) {
+	err, _ = table.router.postModalDocument(ctx, table.router.getEndpoint(table.Page), table.form("TABLE-DELETE", index))
+	return
+}
+
+func (table *ModalTable[R]) form(action string, index int) map[string]string {
+	return map[string]string{
+		"tableid": table.TableID,
+		"stateid": "",
+		"action":  action,
+		"index":   fmt.Sprintf("%d", index),
+	}
+}
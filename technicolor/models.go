@@ -1,16 +1,76 @@
 package technicolor
 
+import (
+	"strings"
+	"time"
+)
+
 type PortForwarded struct {
 	Enabled  bool
 	Name     string
 	Protocol string
-	WanPort  int
-	LanPort  int
-	LanIp    string
-	LanMac   string
+	// a single port has the same start and end
+	WanPortStart int
+	WanPortEnd   int
+	LanPortStart int
+	LanPortEnd   int
+	LanIp        string
+	LanMac       string
 }
 
 type PortForwardedWithIndex struct {
 	Index int
 	Data  PortForwarded
 }
+
+// PortForwardedIdentity identifies a rule regardless of its position in
+// the router table, which changes every time a previous row is deleted.
+// A rule is identified by its name, protocol and wan port, LanMac is only
+// used to break ties between rules sharing them, e.g. the same service
+// forwarded to two devices. The rules that are still tied are reported as
+// ErrPortForwardedAmbiguous.
+type PortForwardedIdentity struct {
+	Name         string
+	Protocol     string
+	WanPortStart int
+	LanMac       string
+}
+
+func (p *PortForwarded) Identity() PortForwardedIdentity {
+	return PortForwardedIdentity{
+		Name:         p.Name,
+		Protocol:     p.Protocol,
+		WanPortStart: p.WanPortStart,
+		LanMac:       p.LanMac,
+	}
+}
+
+func (identity PortForwardedIdentity) matches(p *PortForwarded) bool {
+	return strings.EqualFold(identity.Name, p.Name) &&
+		strings.EqualFold(identity.Protocol, p.Protocol) &&
+		identity.WanPortStart == p.WanPortStart
+}
+
+// sameSettings tells if the rules have the same settings, the mac address
+// is ignored since the router fills it from the lan ip
+func (p *PortForwarded) sameSettings(other *PortForwarded) bool {
+	return p.Enabled == other.Enabled &&
+		p.Name == other.Name &&
+		strings.EqualFold(p.Protocol, other.Protocol) &&
+		p.WanPortStart == other.WanPortStart &&
+		p.WanPortEnd == other.WanPortEnd &&
+		p.LanPortStart == other.LanPortStart &&
+		p.LanPortEnd == other.LanPortEnd &&
+		p.LanIp == other.LanIp
+}
+
+// RouterInfo is the identity of the device, as shown by the gateway
+// overview of the web UI
+type RouterInfo struct {
+	ProductName     string
+	HardwareVersion string
+	FirmwareVersion string
+	SerialNumber    string
+	MacAddress      string
+	Uptime          time.Duration
+}
@@ -0,0 +1,245 @@
+package technicolor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// the value replacing the secrets in the recorded exchanges
+const REDACTED = "REDACTED"
+
+// the shortest secret that is redacted, shorter values would match
+// unrelated text of the pages
+const MIN_REDACTED_LENGTH = 4
+
+// the mac addresses of the router and of the lan devices, redacted wherever
+// they appear, also without separators since the router names the unknown
+// devices after them, e.g. Unknown-0200c0a8010a
+var MAC_ADDRESS_REGEX = regexp.MustCompile(`\b([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}\b`)
+
+// Exchange is a request to the router and its response, as saved by the
+// Recorder. The body of the response is saved next to it in BodyFile.
+type Exchange struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Query       string            `json:"query,omitempty"`
+	Form        map[string]string `json:"form,omitempty"`
+	StatusCode  int               `json:"status_code"`
+	ContentType string            `json:"content_type,omitempty"`
+	BodyFile    string            `json:"body_file"`
+	Body        []byte            `json:"-"`
+}
+
+// Recorder is an http.RoundTripper saving the exchanges with the router in
+// Dir, to replay the pages of a real router against the parsers. The CSRF
+// tokens, the cookies, the serial number and the mac addresses are
+// redacted, the SRP handshake is not recorded at all since it would allow
+// to guess the password offline.
+type Recorder struct {
+	Transport http.RoundTripper
+	Dir       string
+
+	mu      sync.Mutex
+	count   int
+	secrets map[string]bool
+}
+
+// NewRecorder records the exchanges of transport in dir, which is created
+// if needed
+func NewRecorder(transport http.RoundTripper, dir string) *Recorder {
+	return &Recorder{
+		Transport: transport,
+		Dir:       dir,
+		secrets:   map[string]bool{},
+	}
+}
+
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var form url.Values
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ = url.ParseQuery(string(body))
+	}
+
+	resp, err := recorder.Transport.RoundTrip(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	exchange := Exchange{
+		Method:      req.Method,
+		Path:        path.Clean("/" + req.URL.Path),
+		Query:       req.URL.RawQuery,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+	}
+
+	if exchange.Path == TECHNICOLOR_ENDPOINT_AUTHENTICATE {
+		return resp, nil
+	}
+
+	if len(form) > 0 {
+		exchange.Form = map[string]string{}
+		for key := range form {
+			exchange.Form[key] = form.Get(key)
+		}
+	}
+
+	// a failure to record must not fail the request to the router
+	if err := recorder.record(req, resp, exchange); err != nil {
+		log.Printf("[WARN] Failed to record %s %s: %v", exchange.Method, exchange.Path, err)
+	}
+
+	return resp, nil
+}
+
+func (recorder *Recorder) record(req *http.Request, resp *http.Response, exchange Exchange) error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for _, cookie := range append(req.Cookies(), resp.Cookies()...) {
+		recorder.addSecret(cookie.Value)
+	}
+
+	recorder.addSecret(exchange.Form["CSRFtoken"])
+
+	if strings.Contains(exchange.ContentType, "html") {
+		if document, err := goquery.NewDocumentFromReader(bytes.NewReader(exchange.Body)); err == nil {
+			recorder.addSecret(document.Find(`meta[name="CSRFtoken"]`).AttrOr("content", ""))
+			values := controlValues(document)
+			recorder.addSecret(values[strings.ToLower(ROUTER_INFO_SERIAL_NUMBER)])
+			recorder.addSecret(values[strings.ToLower(ROUTER_INFO_MAC_ADDRESS)])
+		}
+	}
+
+	texts := []string{exchange.Query, string(exchange.Body)}
+	for _, value := range exchange.Form {
+		texts = append(texts, value)
+	}
+	for _, text := range texts {
+		for _, mac := range MAC_ADDRESS_REGEX.FindAllString(text, -1) {
+			digits := strings.NewReplacer(":", "", "-", "").Replace(mac)
+			recorder.addSecret(strings.ToLower(digits))
+			recorder.addSecret(strings.ToUpper(digits))
+		}
+	}
+
+	for key, value := range exchange.Form {
+		exchange.Form[key] = recorder.redact(value)
+	}
+	exchange.Query = recorder.redact(exchange.Query)
+	exchange.Body = []byte(recorder.redact(string(exchange.Body)))
+
+	recorder.count++
+
+	name := strings.ReplaceAll(strings.Trim(exchange.Path, "/"), "/", "_")
+	if name == "" {
+		name = "index"
+	}
+	base := fmt.Sprintf("%04d-%s-%s", recorder.count, strings.ToLower(exchange.Method), name)
+
+	exchange.BodyFile = base + ".txt"
+	if strings.Contains(exchange.ContentType, "html") {
+		exchange.BodyFile = base + ".html"
+	}
+
+	if err := os.MkdirAll(recorder.Dir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(recorder.Dir, exchange.BodyFile), exchange.Body, 0644); err != nil {
+		return err
+	}
+
+	meta, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(recorder.Dir, base+".json"), append(meta, '\n'), 0644)
+}
+
+func (recorder *Recorder) addSecret(secret string) {
+	if len(secret) >= MIN_REDACTED_LENGTH && secret != REDACTED {
+		recorder.secrets[secret] = true
+	}
+}
+
+// redact replaces the secrets seen so far, the longest first in case one
+// contains another, and the mac addresses
+func (recorder *Recorder) redact(text string) string {
+	var secrets []string
+	for secret := range recorder.secrets {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, REDACTED)
+	}
+	return MAC_ADDRESS_REGEX.ReplaceAllString(text, REDACTED)
+}
+
+// ReadExchanges loads the exchanges saved by a Recorder in dir, in the
+// order they were recorded
+func ReadExchanges(dir string) (err error, exchanges []Exchange) {
+	files, err := filepath.Glob(filepath.Join(dir, "[0-9]*-*.json"))
+
+	if err != nil {
+		return err, nil
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		var exchange Exchange
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err, nil
+		}
+
+		if err = json.Unmarshal(content, &exchange); err != nil {
+			return fmt.Errorf("%s: %w", file, err), nil
+		}
+
+		exchange.Body, err = os.ReadFile(filepath.Join(dir, exchange.BodyFile))
+		if err != nil {
+			return err, nil
+		}
+
+		exchanges = append(exchanges, exchange)
+	}
+	return
+}
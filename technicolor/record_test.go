@@ -0,0 +1,94 @@
+package technicolor_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"terraform-provider-technicolor/technicolor"
+	"terraform-provider-technicolor/technicolortest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReplayRouter(t *testing.T, dir string) *technicolor.TechnicolorRouter {
+	replay, err := technicolortest.NewReplay(dir)
+	require.NoError(t, err)
+
+	router := technicolor.NewTechnicolorRouter("router.invalid", 80)
+	router.WrapTransport(func(http.RoundTripper) http.RoundTripper {
+		return replay
+	})
+	return router
+}
+
+func TestRecordReplay(t *testing.T) {
+	gateway := technicolortest.NewGateway("admin", "secret")
+	t.Cleanup(gateway.Close)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	dir := t.TempDir()
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+	router.WrapTransport(func(transport http.RoundTripper) http.RoundTripper {
+		return technicolor.NewRecorder(transport, dir)
+	})
+
+	err, _ := router.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+
+	err, recorded := router.GetAllPortForwarded(context.Background())
+	require.NoError(t, err)
+
+	err, _ = router.GetRouterInfo(context.Background())
+	require.NoError(t, err)
+
+	web := technicolor.PortForwarded{Enabled: true, Name: "web", Protocol: "TCP", WanPortStart: 80, WanPortEnd: 80, LanPortStart: 80, LanPortEnd: 80, LanIp: "192.168.1.10"}
+	require.NoError(t, router.AddPortForwarded(context.Background(), &web))
+
+	secrets := []string{router.CSRFToken, router.SessionID(), gateway.RouterInfo().SerialNumber, gateway.RouterInfo().MacAddress, rule.LanMac, strings.ReplaceAll(rule.LanMac, ":", "")}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		// the SRP handshake is not recorded
+		assert.NotContains(t, file, "authenticate")
+
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, secret := range secrets {
+			assert.NotContains(t, string(content), secret, file)
+		}
+	}
+
+	// the replay serves the table as it was first recorded
+	replayed := newReplayRouter(t, dir)
+
+	for i := range recorded {
+		recorded[i].Data.LanMac = technicolor.REDACTED
+	}
+
+	err, portsForwarded := replayed.GetAllPortForwarded(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, recorded, portsForwarded)
+
+	err, info := replayed.GetRouterInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, technicolor.REDACTED, info.SerialNumber)
+	assert.Equal(t, technicolor.REDACTED, info.MacAddress)
+
+	require.NoError(t, replayed.AddPortForwarded(context.Background(), &web))
+
+	// a request that was never recorded
+	err = replayed.Logout(context.Background())
+	var statusError *technicolor.StatusError
+	require.ErrorAs(t, err, &statusError)
+	assert.Equal(t, http.StatusNotFound, statusError.StatusCode)
+}
@@ -0,0 +1,154 @@
+package technicolor
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const DEFAULT_RETRIES = 3
+
+const DEFAULT_RETRY_BACKOFF = 500 * time.Millisecond
+
+const DEFAULT_RETRY_MAX_BACKOFF = 10 * time.Second
+
+// isTransient tells if a request failed because of the router or the
+// network rather than because of the request itself, so that sending it
+// again may succeed: 5xx answers, dropped connections and single requests
+// which took longer than RequestTimeout.
+func isTransient(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var statusError *StatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode >= 500 || statusError.StatusCode == http.StatusTooManyRequests
+	}
+
+	// a certificate does not get better by asking again
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	if errors.Is(err, ErrCertificateMismatch) || errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) {
+		return false
+	}
+
+	// any other transport error: refused, reset or dropped connections and
+	// requests cut by RequestTimeout
+	var urlError *url.Error
+	return errors.As(err, &urlError) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isNotSent tells if the request certainly did not reach the router, so it
+// can be sent again even if it is not idempotent
+func isNotSent(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
+// backoff returns the wait before the given retry, doubling from
+// RetryBackoff up to RetryMaxBackoff
+func (router *TechnicolorRouter) backoff(retry int) time.Duration {
+	wait := router.RetryBackoff
+	for i := 0; i < retry && wait < router.RetryMaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if router.RetryMaxBackoff > 0 && wait > router.RetryMaxBackoff {
+		wait = router.RetryMaxBackoff
+	}
+	return wait
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttle waits until MinRequestInterval has passed since the previous
+// request, the lua web server of the router does not cope well with bursts
+func (router *TechnicolorRouter) throttle(ctx context.Context) error {
+	if router.MinRequestInterval <= 0 {
+		return nil
+	}
+
+	router.throttleMu.Lock()
+	now := time.Now()
+	if router.nextRequest.Before(now) {
+		router.nextRequest = now
+	}
+	wait := router.nextRequest.Sub(now)
+	router.nextRequest = router.nextRequest.Add(router.MinRequestInterval)
+	router.throttleMu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
+
+// send performs the request built by newRequest, retrying with backoff as
+// long as retryable accepts the error
+func (router *TechnicolorRouter) send(ctx context.Context, newRequest func() (*http.Request, error), retryable func(context.Context, error) bool) (err error, body []byte) {
+	for retry := 0; ; retry++ {
+		request, err := newRequest()
+
+		if err != nil {
+			return err, nil
+		}
+
+		err, body = router.do(ctx, request)
+
+		if err == nil || retry >= router.Retries || !retryable(ctx, err) {
+			return err, body
+		}
+
+		if sleep(ctx, router.backoff(retry)) != nil {
+			return err, nil
+		}
+	}
+}
+
+// confirmedWrite performs a write which is not idempotent. When it fails in
+// a way that leaves unknown if the router applied it, applied reads the
+// current state: the write is done if it is there, and sent again otherwise.
+func (router *TechnicolorRouter) confirmedWrite(ctx context.Context, write func() error, applied func() (error, bool)) (err error) {
+	for retry := 0; ; retry++ {
+		err = write()
+
+		if err == nil || retry >= router.Retries || !isTransient(ctx, err) {
+			return err
+		}
+
+		if sleep(ctx, router.backoff(retry)) != nil {
+			return err
+		}
+
+		checkErr, ok := applied()
+
+		if checkErr != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+	}
+}
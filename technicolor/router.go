@@ -1,59 +1,175 @@
 package technicolor
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-
-	"github.com/gocolly/colly/v2"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
-// class
+// A TechnicolorRouter can be shared between goroutines: every request owns
+// its response, the session is guarded by mu and the table writes, which
+// depend on the current row indexes, are serialized by writeMu.
+// When the router drops the session the client logs in again with the last
+// credentials and replays the request once. A login waits for the requests in
+// flight, which hold loginMu for reading, as the router hands out a new
+// session cookie to every request that has not a valid one.
 type TechnicolorRouter struct {
 	Address   string
 	Port      int
 	CSRFToken string
 	url       string
 	sessionID string
-	collector *colly.Collector
+	client    *http.Client
 	user      *CryptoUser
 	s         []byte
 	B         []byte
 	M         []byte
+	mu        sync.RWMutex
+	loginMu   sync.RWMutex
+	writeMu   sync.Mutex
+
+	// the credentials of the last successful login, kept to login again
+	// when the router drops the session
+	username string
+	password string
+	// incremented on every successful login, tells if a session error
+	// happened before or after the last login
+	logins uint64
+	// false when the session was resumed from a cookie, in which case it
+	// belongs to someone else and is not logged out
+	ownsSession bool
+
+	// RequestTimeout bounds every single http request, 0 means no limit
+	RequestTimeout time.Duration
+	// Retries is how many times a request failed for a transient reason is
+	// sent again: reads always, writes only when it is safe, see send and
+	// confirmedWrite. The wait doubles from RetryBackoff up to RetryMaxBackoff.
+	Retries         int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// MinRequestInterval spaces out the requests, 0 means no limit
+	MinRequestInterval time.Duration
+	// the SRP parameters of the login, SHA256 with the 2048 bits group on
+	// the TIM HUB; NHex and GHex are only used with NG_CUSTOM
+	HashAlgorithm int
+	NGType        int
+	NHex          string
+	GHex          string
+	// EphemeralSize is the size in bytes of the SRP private ephemeral,
+	// 0 means the size of the group, see EphemeralSize
+	EphemeralSize int
+
+	throttleMu  sync.Mutex
+	nextRequest time.Time
 }
 
+const DEFAULT_REQUEST_TIMEOUT = 30 * time.Second
+
+// NewTechnicolorRouter connects to the web UI over plain HTTP
 func NewTechnicolorRouter(address string, port int) *TechnicolorRouter {
+	return newTechnicolorRouter("http", address, port, http.DefaultTransport)
+}
+
+// NewTechnicolorRouterWithTLS connects to the web UI over HTTPS, so that
+// the SRP handshake and the CSRF tokens do not cross the network in clear
+func NewTechnicolorRouterWithTLS(address string, port int, options TLSOptions) (err error, router *TechnicolorRouter) {
+	err, tlsConfig := newTLSConfig(address, options)
+
+	if err != nil {
+		return err, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return nil, newTechnicolorRouter("https", address, port, transport)
+}
+
+func newTechnicolorRouter(scheme string, address string, port int, transport http.RoundTripper) *TechnicolorRouter {
+	jar, _ := cookiejar.New(nil)
+
 	return &TechnicolorRouter{
 		Address: address,
 		Port:    port,
-		url:     fmt.Sprintf("http://%s:%d", address, port),
-		collector: colly.NewCollector(
-			colly.AllowURLRevisit(),
-			colly.Headers(map[string]string{}),
-		),
+		url:     fmt.Sprintf("%s://%s:%d", scheme, address, port),
+		client: &http.Client{
+			Jar:       jar,
+			Transport: transport,
+		},
+		RequestTimeout:  DEFAULT_REQUEST_TIMEOUT,
+		Retries:         DEFAULT_RETRIES,
+		RetryBackoff:    DEFAULT_RETRY_BACKOFF,
+		RetryMaxBackoff: DEFAULT_RETRY_MAX_BACKOFF,
+		HashAlgorithm:   SHA256,
+		NGType:          NG_2048,
 	}
 }
 
+// WrapTransport wraps the transport of the router, e.g. to record the
+// exchanges with a Recorder. It must be called before the first request.
+func (router *TechnicolorRouter) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	transport := router.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	router.client.Transport = wrap(transport)
+}
+
 func (router *TechnicolorRouter) getEndpoint(endpoint string) string {
 	return fmt.Sprintf("%s/%s", router.url, endpoint)
 }
 
-func (router *TechnicolorRouter) Login(username string, password string) (err error, isAuthenticated bool) {
+func (router *TechnicolorRouter) csrfToken() string {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	return router.CSRFToken
+}
+
+func (router *TechnicolorRouter) setCSRFToken(token string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.CSRFToken = token
+}
+
+func (router *TechnicolorRouter) Login(ctx context.Context, username string, password string) (err error, isAuthenticated bool) {
+	router.loginMu.Lock()
+	defer router.loginMu.Unlock()
 
-	err = router.getCSRFToken()
+	return router.login(ctx, username, password)
+}
+
+func (router *TechnicolorRouter) login(ctx context.Context, username string, password string) (err error, isAuthenticated bool) {
+	err = router.getCSRFToken(ctx)
 
 	if err != nil {
 		return err, false
 	}
 
-	router.user = NewCryptoUser(username, password, SHA256, NG_2048)
+	err, user := NewCryptoUserWithRandom(username, password, router.HashAlgorithm, router.NGType, router.NHex, router.GHex, rand.Reader, router.EphemeralSize)
+
+	if err != nil {
+		return err, false
+	}
 
-	I, A := router.user.StartAuthentication()
+	I, A := user.StartAuthentication()
 
 	// {"CSRFtoken": token, "I": uname, "A": binascii.hexlify(A)}
-	err, responseSB := router.authenticate(map[string]string{
-		"CSRFtoken": router.CSRFToken,
+	err, responseSB := router.authenticate(ctx, map[string]string{
+		"CSRFtoken": router.csrfToken(),
 		"I":         I,
 		"A":         hex.EncodeToString(A),
 	})
@@ -62,34 +178,64 @@ func (router *TechnicolorRouter) Login(username string, password string) (err er
 		return err, false
 	}
 
-	err = router.getSAndB(responseSB)
+	err, s, B := getSAndB(responseSB)
 
 	if err != nil {
 		return err, false
 	}
 
-	computedM, _ := router.user.ProcessChallenge(router.s, router.B)
+	computedM, _ := user.ProcessChallenge(s, B)
 
-	err, responseChallenge := router.authenticate(map[string]string{
-		"CSRFtoken": router.CSRFToken,
+	if computedM == nil {
+		return fmt.Errorf("%w: the router challenge failed the SRP-6a safety check", ErrAuthenticationFailed), false
+	}
+
+	err, responseChallenge := router.authenticate(ctx, map[string]string{
+		"CSRFtoken": router.csrfToken(),
 		"M":         hex.EncodeToString(computedM),
 	})
 
-	err = router.getM(responseChallenge)
+	if err != nil {
+		return err, false
+	}
+
+	err, M := getM(responseChallenge)
 
 	if err != nil {
 		return err, false
 	}
 
-	isAuthenticated = router.user.ValidateAuthentication(router.M)
+	isAuthenticated = user.ValidateAuthentication(M)
 
-	if isAuthenticated {
-		router.sessionID = router.collector.Cookies(router.url)[0].Value // TODO check if this is the correct cookie
+	if !isAuthenticated {
+		return fmt.Errorf("%w: the router proof does not match", ErrAuthenticationFailed), false
+	}
+
+	router.mu.Lock()
+	router.user = user
+	router.s = s
+	router.B = B
+	router.M = M
+	router.mu.Unlock()
+
+	router.mu.Lock()
+	router.sessionID = router.getSessionID()
+	router.mu.Unlock()
 
-		// update csrf with new session id
-		router.getCSRFTokenAfterLogin()
+	// update csrf with new session id
+	err = router.getCSRFTokenAfterLogin(ctx)
+
+	if err != nil {
+		return err, false
 	}
 
+	router.mu.Lock()
+	router.username = username
+	router.password = password
+	router.logins++
+	router.ownsSession = true
+	router.mu.Unlock()
+
 	// GetCSRFToken
 	// Initialize CryptoUser
 	// Authenticate
@@ -99,111 +245,440 @@ func (router *TechnicolorRouter) Login(username string, password string) (err er
 	return nil, isAuthenticated
 }
 
-func (router *TechnicolorRouter) getCSRFToken() (err error) {
-	router.collector.OnHTML("head > meta:nth-child(3)", func(e *colly.HTMLElement) {
-		// log.Println("getCSRFToken.OnHTML()")
-		router.CSRFToken = e.Attr("content")
-	})
+// Resume reuses the session of an existing cookie instead of opening a new
+// one, which matters as the router only allows a few admin sessions at a
+// time. If the session is not valid anymore it logs in with the credentials,
+// which are also used to login again when the session expires later.
+func (router *TechnicolorRouter) Resume(ctx context.Context, sessionID string, username string, password string) (err error, resumed bool) {
+	router.loginMu.Lock()
+	defer router.loginMu.Unlock()
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("getCSRFToken.OnError()")
-		log.Println("getCSRFToken => error:", err, r.Body)
-	})
+	routerURL, err := url.Parse(router.url)
+
+	if err != nil {
+		return err, false
+	}
+
+	router.client.Jar.SetCookies(routerURL, []*http.Cookie{{Name: SESSION_COOKIE, Value: sessionID, Path: "/"}})
+
+	err, document := router.getDocument(ctx, router.url)
+
+	if err != nil {
+		return err, false
+	}
 
-	err = router.collector.Visit(router.url)
+	if isLoginPage(document) {
+		err, _ = router.login(ctx, username, password)
+		return err, false
+	}
 
-	router.collector.OnHTMLDetach("head > meta:nth-child(3)")
+	token, ok := document.Find(CSRF_TOKEN_SELECTOR).Attr("content")
 
-	return err
+	if !ok || token == "" {
+		return &LayoutError{Page: router.url, Element: "CSRF token meta tag"}, false
+	}
+
+	router.mu.Lock()
+	router.CSRFToken = token
+	router.sessionID = sessionID
+	router.username = username
+	router.password = password
+	router.logins++
+	router.ownsSession = false
+	router.mu.Unlock()
+
+	return nil, true
 }
 
-func (router *TechnicolorRouter) getCSRFTokenAfterLogin() (err error) {
-	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_LOGIN)
-	// url := fmt.Sprintf("%s/login.lp?action=lastaccess", router.url)
+// SessionID returns the cookie of the current session, which can be passed
+// to Resume by another client
+func (router *TechnicolorRouter) SessionID() string {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	return router.sessionID
+}
 
-	router.collector.OnHTML("head > meta:nth-child(3)", func(e *colly.HTMLElement) {
-		// log.Println("getCSRFToken.OnHTML()")
-		router.CSRFToken = e.Attr("content")
-	})
+// OwnsSession tells if the current session was opened by Login, and not
+// resumed from the cookie of someone else
+func (router *TechnicolorRouter) OwnsSession() bool {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	return router.ownsSession
+}
+
+// Logout closes the current session, like the sign out button of the web UI.
+// The credentials are forgotten, so the client does not login again by
+// itself afterwards.
+func (router *TechnicolorRouter) Logout(ctx context.Context) (err error) {
+	router.loginMu.Lock()
+	defer router.loginMu.Unlock()
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("getCSRFToken.OnError()")
-		log.Println("getCSRFToken => error:", err, r.Body)
+	err, _ = router.post(ctx, router.getEndpoint(TECHNICOLOR_ENDPOINT_LOGOUT), map[string]string{
+		"do_signout": "1",
+		"CSRFtoken":  router.csrfToken(),
 	})
 
-	err = router.collector.Visit(url)
+	if err != nil {
+		return err
+	}
 
-	router.collector.OnHTMLDetach("head > meta:nth-child(3)")
+	router.mu.Lock()
+	router.sessionID = ""
+	router.CSRFToken = ""
+	router.user = nil
+	router.username = ""
+	router.password = ""
+	router.ownsSession = false
+	router.mu.Unlock()
 
-	return err
+	return nil
 }
 
-func (router *TechnicolorRouter) authenticate(data map[string]string) (err error, response map[string]string) {
-	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_AUTHENTICATE)
-	// url := fmt.Sprintf("%s/authenticate", router.url)
+func (router *TechnicolorRouter) getSessionID() string {
+	routerURL, _ := url.Parse(router.url)
+
+	for _, cookie := range router.client.Jar.Cookies(routerURL) {
+		if cookie.Name == SESSION_COOKIE {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func (router *TechnicolorRouter) getCSRFToken(ctx context.Context) (err error) {
+	return router.getCSRFTokenFrom(ctx, router.url)
+}
+
+func (router *TechnicolorRouter) getCSRFTokenAfterLogin(ctx context.Context) (err error) {
+	return router.getCSRFTokenFrom(ctx, router.getEndpoint(TECHNICOLOR_ENDPOINT_LOGIN))
+}
+
+// the meta tag holding the CSRF token of the session, found by name since
+// its position in the head changes between pages
+const CSRF_TOKEN_SELECTOR = `head > meta[name="CSRFtoken"]`
+
+func (router *TechnicolorRouter) getCSRFTokenFrom(ctx context.Context, target string) (err error) {
+	err, document := router.getDocument(ctx, target)
+
+	if err != nil {
+		return err
+	}
 
-	// router.collector.OnHTML("head > meta:nth-child(3)", func(e *colly.HTMLElement) {
-	// log.Println("authenticate.OnHTML()")
-	// log.Println(e.Attr("content"))
-	// })
+	token, ok := document.Find(CSRF_TOKEN_SELECTOR).Attr("content")
 
-	router.collector.OnResponse(func(r *colly.Response) {
-		// log.Println("authenticate.OnResponse()")
-		// print cookie
-		// fmt.Println(router.collector.Cookies(r.Request.URL.String()))
-		if r.StatusCode != 200 {
-			err = fmt.Errorf("status code error: %d %s", r.StatusCode, r.Body)
+	if !ok || token == "" {
+		return &LayoutError{Page: target, Element: "CSRF token meta tag"}
+	}
+
+	router.setCSRFToken(token)
+	return nil
+}
+
+// isLoginPage tells if the router answered with the login form, which
+// happens for every page once the session is expired
+func isLoginPage(document *goquery.Document) bool {
+	return document.Find("form#login, #srp_username").Length() > 0
+}
+
+// findValidationError collects the messages the web UI shows next to the
+// refused values: a help-inline in a control group or a table cell marked
+// with the error class, and the alert-error blocks about the whole form
+func findValidationError(target string, document *goquery.Document) *ValidationError {
+	var fieldErrors []FieldError
+
+	document.Find(".alert-error").Each(func(_ int, alert *goquery.Selection) {
+		if message := strings.TrimSpace(alert.Text()); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{Message: message})
+		}
+	})
+
+	document.Find(".error .help-inline").Each(func(_ int, help *goquery.Selection) {
+		message := strings.TrimSpace(help.Text())
+		if message == "" {
 			return
 		}
+		field := help.Closest(".error").Find("input[name], select[name], textarea[name]").First().AttrOr("name", "")
+		fieldErrors = append(fieldErrors, FieldError{Field: field, Message: message})
+	})
+
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+
+	page := target
+	if parsed, err := url.Parse(target); err == nil {
+		page = path.Clean(parsed.Path)
+	}
+
+	return &ValidationError{Page: page, Errors: fieldErrors}
+}
+
+// isSessionError tells if the request failed because the router does not
+// accept the session anymore, in which case it was not executed
+func isSessionError(err error) bool {
+	return errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrCSRFRejected)
+}
+
+// withSession runs the requests of fn while no login is in progress and
+// returns the login count they were sent with
+func (router *TechnicolorRouter) withSession(fn func()) (logins uint64) {
+	router.loginMu.RLock()
+	defer router.loginMu.RUnlock()
+
+	router.mu.RLock()
+	logins = router.logins
+	router.mu.RUnlock()
+
+	fn()
+	return
+}
 
-		// var jsonResponse map[string]string
-		err = json.Unmarshal([]byte(r.Body), &response)
+// relogin redoes the SRP handshake with the last credentials, unless another
+// request already did it since the given login count
+func (router *TechnicolorRouter) relogin(ctx context.Context, logins uint64) (err error) {
+	router.loginMu.Lock()
+	defer router.loginMu.Unlock()
+
+	router.mu.RLock()
+	username, password, current := router.username, router.password, router.logins
+	router.mu.RUnlock()
+
+	if current != logins {
+		return nil
+	}
+
+	if username == "" {
+		return ErrSessionExpired
+	}
+
+	err, _ = router.login(ctx, username, password)
+	return
+}
+
+// getModalDocument loads a page that is only available to an authenticated
+// session, logging in again once if the session was dropped
+func (router *TechnicolorRouter) getModalDocument(ctx context.Context, target string) (err error, document *goquery.Document) {
+	logins := router.withSession(func() {
+		err, document = router.getModalDocumentOnce(ctx, target)
 	})
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("authenticate.OnError()")
-		log.Println("authenticate => error:", err, r.Body)
+	if !isSessionError(err) {
+		return
+	}
+
+	if loginErr := router.relogin(ctx, logins); loginErr != nil {
+		return fmt.Errorf("%v, login again: %w", err, loginErr), nil
+	}
+
+	router.withSession(func() {
+		err, document = router.getModalDocumentOnce(ctx, target)
+	})
+	return
+}
+
+func (router *TechnicolorRouter) getModalDocumentOnce(ctx context.Context, target string) (err error, document *goquery.Document) {
+	err, document = router.getDocument(ctx, target)
+
+	if err == nil && isLoginPage(document) {
+		return ErrSessionExpired, nil
+	}
+	return
+}
+
+// postModalDocument submits the forms in order to a page that is only
+// available to an authenticated session and returns the last answer.
+// The CSRF token is added to every form. Some actions only make sense after
+// the previous ones in the same session (TABLE-MODIFY after TABLE-EDIT), so
+// if the session is dropped halfway the client logs in again and replays
+// all of them once.
+func (router *TechnicolorRouter) postModalDocument(ctx context.Context, target string, forms ...map[string]string) (err error, document *goquery.Document) {
+	logins := router.withSession(func() {
+		err, document = router.postModalDocumentOnce(ctx, target, forms)
 	})
 
-	err = router.collector.Post(url, data)
+	if !isSessionError(err) {
+		return
+	}
+
+	if loginErr := router.relogin(ctx, logins); loginErr != nil {
+		return fmt.Errorf("%v, login again: %w", err, loginErr), nil
+	}
+
+	router.withSession(func() {
+		err, document = router.postModalDocumentOnce(ctx, target, forms)
+	})
+	return
+}
+
+func (router *TechnicolorRouter) postModalDocumentOnce(ctx context.Context, target string, forms []map[string]string) (err error, document *goquery.Document) {
+	for _, form := range forms {
+		data := map[string]string{}
+		for key, value := range form {
+			data[key] = value
+		}
+		data["CSRFtoken"] = router.csrfToken()
+
+		err, document = router.postDocument(ctx, target, data)
+
+		if err != nil {
+			return err, nil
+		}
+
+		if isLoginPage(document) {
+			return ErrSessionExpired, nil
+		}
+
+		// the router answers 200 and shows the form again when a value is refused
+		if validationErr := findValidationError(target, document); validationErr != nil {
+			return validationErr, document
+		}
+	}
+	return
+}
+
+func (router *TechnicolorRouter) authenticate(ctx context.Context, data map[string]string) (err error, response map[string]string) {
+	err, body := router.post(ctx, router.getEndpoint(TECHNICOLOR_ENDPOINT_AUTHENTICATE), data)
+
+	if err != nil {
+		return err, nil
+	}
+
+	err = json.Unmarshal(body, &response)
+
+	if err != nil {
+		return fmt.Errorf("%w: invalid authenticate response: %v", ErrUnexpectedLayout, err), nil
+	}
+	return
+}
+
+// get performs a GET request and returns the body of a 200 response,
+// a GET does not change anything so it is retried on transient errors
+func (router *TechnicolorRouter) get(ctx context.Context, target string) (err error, body []byte) {
+	return router.send(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	}, isTransient)
+}
+
+// post submits the data as a form and returns the body of a 200 response,
+// it is only retried when the request did not reach the router
+func (router *TechnicolorRouter) post(ctx context.Context, target string, data map[string]string) (err error, body []byte) {
+	form := url.Values{}
+	for key, value := range data {
+		form.Set(key, value)
+	}
+
+	return router.send(ctx, func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request, nil
+	}, isNotSent)
+}
 
-	// router.collector.OnHTMLDetach("head > meta:nth-child(3)")
+// do sends the request, bounded by RequestTimeout on top of the deadline of
+// ctx and spaced out by MinRequestInterval
+func (router *TechnicolorRouter) do(ctx context.Context, request *http.Request) (err error, body []byte) {
+	if err = router.throttle(ctx); err != nil {
+		return err, nil
+	}
+
+	if router.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, router.RequestTimeout)
+		defer cancel()
+		request = request.WithContext(ctx)
+	}
+
+	response, err := router.client.Do(request)
+
+	if err != nil {
+		return err, nil
+	}
+
+	defer response.Body.Close()
+
+	body, err = io.ReadAll(response.Body)
+
+	if err != nil {
+		return err, nil
+	}
+
+	if response.StatusCode != 200 {
+		return &StatusError{StatusCode: response.StatusCode, Body: string(body)}, nil
+	}
+
+	return nil, body
+}
+
+func (router *TechnicolorRouter) getDocument(ctx context.Context, target string) (err error, document *goquery.Document) {
+	err, body := router.get(ctx, target)
+
+	if err != nil {
+		return err, nil
+	}
+
+	document, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
+	return
+}
+
+func (router *TechnicolorRouter) postDocument(ctx context.Context, target string, data map[string]string) (err error, document *goquery.Document) {
+	err, body := router.post(ctx, target, data)
+
+	if err != nil {
+		return err, nil
+	}
+
+	document, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
 	return
 }
 
-func (router *TechnicolorRouter) getSAndB(authenticateResponse map[string]string) (err error) {
+func getSAndB(authenticateResponse map[string]string) (err error, s []byte, B []byte) {
 	// type SAndBResponse struct {
 	// 	S string `json:"s"`
 	// 	B string `json:"b"`
 	// }
 
+	if _, containsError := authenticateResponse["error"]; containsError {
+		err = fmt.Errorf("%w: %v", ErrAuthenticationFailed, authenticateResponse)
+		return
+	}
+
 	if _, ok := authenticateResponse["s"]; !ok {
-		err = fmt.Errorf("s not found in response")
+		err = &LayoutError{Page: "authenticate response", Element: "s"}
 		return
 	}
 
 	if _, ok := authenticateResponse["B"]; !ok {
-		err = fmt.Errorf("B not found in response")
+		err = &LayoutError{Page: "authenticate response", Element: "B"}
+		return
+	}
+
+	s, err = hex.DecodeString(authenticateResponse["s"])
+
+	if err != nil {
 		return
 	}
 
-	router.s, err = hex.DecodeString(authenticateResponse["s"])
-	router.B, err = hex.DecodeString(authenticateResponse["B"])
+	B, err = hex.DecodeString(authenticateResponse["B"])
 	return
 }
 
-func (router *TechnicolorRouter) getM(authenticateResponse map[string]string) (err error) {
+func getM(authenticateResponse map[string]string) (err error, M []byte) {
 	_, containsError := authenticateResponse["error"]
 
 	if containsError {
-		err = fmt.Errorf("error in response: %v", authenticateResponse)
+		err = fmt.Errorf("%w: %v", ErrAuthenticationFailed, authenticateResponse)
 		return
 	}
 
 	if _, ok := authenticateResponse["M"]; !ok {
-		err = fmt.Errorf("M not found in response")
+		err = &LayoutError{Page: "authenticate response", Element: "M"}
 		return
 	}
-	router.M, err = hex.DecodeString(authenticateResponse["M"])
+	M, err = hex.DecodeString(authenticateResponse["M"])
 	return
 }
@@ -0,0 +1,111 @@
+package technicolor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// the labels of the gateway overview, each value is in the controls of
+// the same control group
+const (
+	ROUTER_INFO_PRODUCT_NAME     = "Product Name"
+	ROUTER_INFO_HARDWARE_VERSION = "Hardware Version"
+	ROUTER_INFO_FIRMWARE_VERSION = "Firmware Version"
+	ROUTER_INFO_SERIAL_NUMBER    = "Serial Number"
+	ROUTER_INFO_MAC_ADDRESS      = "MAC Address"
+	ROUTER_INFO_UPTIME           = "Uptime"
+)
+
+// GetRouterInfo scrapes the gateway overview (gateway-modal). Every field
+// is required, a missing label means the firmware changed the page and is
+// reported as a LayoutError.
+func (router *TechnicolorRouter) GetRouterInfo(ctx context.Context) (err error, info RouterInfo) {
+	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_GATEWAY)
+
+	err, document := router.getModalDocument(ctx, url)
+
+	if err != nil {
+		return err, RouterInfo{}
+	}
+
+	values := controlValues(document)
+
+	fields := []struct {
+		label string
+		value *string
+	}{
+		{ROUTER_INFO_PRODUCT_NAME, &info.ProductName},
+		{ROUTER_INFO_HARDWARE_VERSION, &info.HardwareVersion},
+		{ROUTER_INFO_FIRMWARE_VERSION, &info.FirmwareVersion},
+		{ROUTER_INFO_SERIAL_NUMBER, &info.SerialNumber},
+		{ROUTER_INFO_MAC_ADDRESS, &info.MacAddress},
+	}
+
+	for _, field := range fields {
+		value, ok := values[strings.ToLower(field.label)]
+		if !ok {
+			return &LayoutError{Page: TECHNICOLOR_ENDPOINT_GATEWAY, Element: field.label}, RouterInfo{}
+		}
+		*field.value = value
+	}
+
+	uptime, ok := values[strings.ToLower(ROUTER_INFO_UPTIME)]
+	if !ok {
+		return &LayoutError{Page: TECHNICOLOR_ENDPOINT_GATEWAY, Element: ROUTER_INFO_UPTIME}, RouterInfo{}
+	}
+
+	info.Uptime, err = parseUptime(uptime)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnexpectedLayout, TECHNICOLOR_ENDPOINT_GATEWAY, err), RouterInfo{}
+	}
+
+	return
+}
+
+// controlValues returns the values of the control groups of a page by
+// their lowercase label
+func controlValues(document *goquery.Document) map[string]string {
+	values := map[string]string{}
+	document.Find(".control-group").Each(func(_ int, group *goquery.Selection) {
+		label := strings.TrimSpace(group.Find(".control-label").First().Text())
+		if label == "" {
+			return
+		}
+		values[strings.ToLower(label)] = strings.TrimSpace(group.Find(".controls").First().Text())
+	})
+	return values
+}
+
+var UPTIME_REGEX = regexp.MustCompile(`(\d+)\s*(days?|hours?|min|sec)`)
+
+var UPTIME_UNITS = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"days":  24 * time.Hour,
+	"hour":  time.Hour,
+	"hours": time.Hour,
+	"min":   time.Minute,
+	"sec":   time.Second,
+}
+
+// parseUptime parses the uptime as formatted by the web UI,
+// e.g. `3 days 4 hours 12 min 5 sec`, the zero units are sometimes omitted
+func parseUptime(uptimeString string) (uptime time.Duration, err error) {
+	uptimeString = strings.TrimSpace(uptimeString)
+
+	matches := UPTIME_REGEX.FindAllStringSubmatch(uptimeString, -1)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid uptime %q", uptimeString)
+	}
+
+	for _, match := range matches {
+		value, _ := strconv.Atoi(match[1])
+		uptime += time.Duration(value) * UPTIME_UNITS[match[2]]
+	}
+	return
+}
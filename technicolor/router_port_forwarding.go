@@ -1,13 +1,12 @@
 package technicolor
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"regexp"
 	"strconv"
 	"strings"
-
-	"github.com/gocolly/colly/v2"
 )
 
 func Bool2int(b bool) int {
@@ -17,131 +16,144 @@ func Bool2int(b bool) int {
 	return 0
 }
 
-func (router *TechnicolorRouter) DeletePortForwarded(index int) (err error) {
-	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_PORT_FORWARDING)
-	// url := fmt.Sprintf("%s/modals/wanservices-modal.lp", router.url)
+// portForwardingTable is the port forwarding table of wanservices-modal.lp
+func (router *TechnicolorRouter) portForwardingTable() *ModalTable[portForwardedRow] {
+	return NewModalTable[portForwardedRow](router, TECHNICOLOR_ENDPOINT_PORT_FORWARDING, "portforwarding")
+}
 
-	router.collector.OnResponse(func(r *colly.Response) {
-		if r.StatusCode != 200 {
-			err = fmt.Errorf("status code error: %d %s", r.StatusCode, r.Body)
-			return
-		}
-	})
+func (router *TechnicolorRouter) DeletePortForwarded(ctx context.Context, index int) (err error) {
+	router.writeMu.Lock()
+	defer router.writeMu.Unlock()
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("DeletePortForwarding.OnError()")
-		log.Println("DeletePortForwarding => error:", err, r.Body)
-	})
+	return router.portForwardingTable().Delete(ctx, index)
+}
 
-	data := map[string]string{
-		"tableid":   "portforwarding",
-		"stateid":   "",
-		"action":    "TABLE-DELETE",
-		"index":     fmt.Sprintf("%d", index),
-		"CSRFtoken": router.CSRFToken,
-	}
+// AddPortForwarded appends a rule. Only an exact duplicate, the same
+// identity to the same lan ip, is refused with ErrPortForwardedExists: a rule
+// with the same name, protocol and wan port to another device is told apart
+// by its mac address, see PortForwardedIdentity.
+func (router *TechnicolorRouter) AddPortForwarded(ctx context.Context, newPortForwarding *PortForwarded) (err error) {
+	// the new index depends on the current table, no other write can happen meanwhile
+	router.writeMu.Lock()
+	defer router.writeMu.Unlock()
 
-	err = router.collector.Post(url, data)
+	err, portsForwarded := router.GetAllPortForwarded(ctx)
 
-	router.collector.OnHTMLDetach("head > meta:nth-child(3)")
-	return
-}
+	if err != nil {
+		return
+	}
 
-func (router *TechnicolorRouter) AddPortForwarded(newPortForwarding *PortForwarded) (err error) {
-	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_PORT_FORWARDING)
-	// url := fmt.Sprintf("%s/modals/wanservices-modal.lp", router.url)
+	identity := newPortForwarding.Identity()
+	for _, portForwarded := range portsForwarded {
+		// the router fills the mac address from the lan ip
+		if identity.matches(&portForwarded.Data) && portForwarded.Data.LanIp == newPortForwarding.LanIp {
+			return fmt.Errorf("%w: %s/%s/%d at index %d", ErrPortForwardedExists, portForwarded.Data.Name, portForwarded.Data.Protocol, portForwarded.Data.WanPortStart, portForwarded.Index)
+		}
+	}
+
+	index := len(portsForwarded) + 1 // index starts from 1
 
-	router.collector.OnResponse(func(r *colly.Response) {
-		if r.StatusCode != 200 {
-			err = fmt.Errorf("status code error: %d %s", r.StatusCode, r.Body)
-			return
+	return router.confirmedWrite(ctx, func() error {
+		return router.portForwardingTable().Add(ctx, index, portForwardedToRow(newPortForwarding))
+	}, func() (error, bool) {
+		err, _ := router.FindPortForwarded(ctx, identity)
+		if errors.Is(err, ErrPortForwardedNotFound) {
+			return nil, false
 		}
+		// a single match or more, the rule was added anyway
+		if err == nil || errors.Is(err, ErrPortForwardedAmbiguous) {
+			return nil, true
+		}
+		return err, false
 	})
+}
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("AddPortForwarding.OnError()")
-		log.Println("AddPortForwarding => error:", err, r.Body)
-	})
+// UpdatePortForwarded modifies the rule at the given index in place
+func (router *TechnicolorRouter) UpdatePortForwarded(ctx context.Context, index int, portForwarding *PortForwarded) (err error) {
+	router.writeMu.Lock()
+	defer router.writeMu.Unlock()
 
-	err, portsForwarded := router.GetAllPortForwarded()
+	return router.portForwardingTable().Modify(ctx, index, portForwardedToRow(portForwarding))
+}
 
-	index := len(portsForwarded) + 1 // index starts from 1
+// portForwardedRow is a row of the port forwarding table, each column is
+// known by its header or by the name of its form parameter. The column
+// between LAN port and Destination IP holds the hostname of the device.
+// A firmware with other headers is read with the positions of the TIM HUB
+// layout the client was first written for.
+type portForwardedRow struct {
+	Enabled  bool      `table:"Status|enabled,input,position=0"`
+	Name     string    `table:"Name|name,position=1"`
+	Protocol string    `table:"Protocol|protocol,position=2"`
+	WanPort  portRange `table:"WAN port|wanport,position=3"`
+	LanPort  portRange `table:"LAN port|lanport,position=4"`
+	LanIp    string    `table:"Destination IP|destinationip,position=6"`
+	// breaks ties between rules with the same identity, some firmwares
+	// hide it
+	LanMac string `table:"Destination MAC|destinationmac,optional,position=7"`
+}
 
-	data := map[string]string{
-		"enabled":       fmt.Sprintf("%d", Bool2int(newPortForwarding.Enabled)),
-		"name":          newPortForwarding.Name,
-		"protocol":      newPortForwarding.Protocol,
-		"wanport":       fmt.Sprintf("%d", newPortForwarding.WanPort),
-		"lanport":       fmt.Sprintf("%d", newPortForwarding.LanPort),
-		"destinationip": newPortForwarding.LanIp,
-		"tableid":       "portforwarding",
-		"stateid":       "",
-		"action":        "TABLE-ADD",
-		"index":         fmt.Sprintf("%d", index),
-		"CSRFtoken":     router.CSRFToken,
-	}
-
-	err = router.collector.Post(url, data)
-	return
+// FormData returns the form of the web UI, the mac address is filled by
+// the router from the lan ip
+func (row portForwardedRow) FormData() map[string]string {
+	return map[string]string{
+		"enabled":       fmt.Sprintf("%d", Bool2int(row.Enabled)),
+		"name":          row.Name,
+		"protocol":      row.Protocol,
+		"wanport":       formatPortRange(row.WanPort.Start, row.WanPort.End),
+		"lanport":       formatPortRange(row.LanPort.Start, row.LanPort.End),
+		"destinationip": row.LanIp,
+	}
 }
 
-func (router *TechnicolorRouter) GetAllPortForwarded() (err error, portsForwarded []PortForwardedWithIndex) {
-	url := router.getEndpoint(TECHNICOLOR_ENDPOINT_PORT_FORWARDING)
-	// url := fmt.Sprintf("%s/modals/wanservices-modal.lp", router.url)
-
-	router.collector.OnHTML("#portforwarding > tbody:nth-child(2)", func(e *colly.HTMLElement) {
-		// log.Println("GetAllPortForwarded.OnHTML()")
-		var index = 1 // index starts from 1
-		e.ForEach("tr", func(_ int, el *colly.HTMLElement) {
-			portForwarded := PortForwarded{}
-			el.ForEach("td", func(j int, el2 *colly.HTMLElement) {
-				switch j {
-				case 0:
-					portForwarded.Enabled = el2.ChildAttr("input", "value") == "1"
-				case 1:
-					portForwarded.Name = el2.Text
-				case 2:
-					portForwarded.Protocol = el2.Text
-				case 3:
-					portForwarded.WanPort, err = parsePort(el2.Text)
-				case 4:
-					portForwarded.LanPort, err = parsePort(el2.Text)
-				// case 5: ???
-				case 6:
-					portForwarded.LanIp = strings.TrimSpace(el2.Text)
-				case 7:
-					portForwarded.LanMac = strings.TrimSpace(el2.Text)
-				}
-			})
-			// log.Printf("%+v", portForwarded)
-			portsForwarded = append(portsForwarded, PortForwardedWithIndex{
-				Index: index,
-				Data:  portForwarded,
-			})
-			index++
-		})
-	})
+func portForwardedToRow(portForwarded *PortForwarded) portForwardedRow {
+	return portForwardedRow{
+		Enabled:  portForwarded.Enabled,
+		Name:     portForwarded.Name,
+		Protocol: portForwarded.Protocol,
+		WanPort:  portRange{Start: portForwarded.WanPortStart, End: portForwarded.WanPortEnd},
+		LanPort:  portRange{Start: portForwarded.LanPortStart, End: portForwarded.LanPortEnd},
+		LanIp:    portForwarded.LanIp,
+		LanMac:   portForwarded.LanMac,
+	}
+}
 
-	router.collector.OnResponse(func(r *colly.Response) {
-		if r.StatusCode != 200 {
-			err = fmt.Errorf("status code error: %d %s", r.StatusCode, r.Body)
-			return
-		}
-	})
+func portForwardedFromRow(row portForwardedRow) PortForwarded {
+	return PortForwarded{
+		Enabled:      row.Enabled,
+		Name:         row.Name,
+		Protocol:     row.Protocol,
+		WanPortStart: row.WanPort.Start,
+		WanPortEnd:   row.WanPort.End,
+		LanPortStart: row.LanPort.Start,
+		LanPortEnd:   row.LanPort.End,
+		LanIp:        row.LanIp,
+		LanMac:       row.LanMac,
+	}
+}
 
-	router.collector.OnError(func(r *colly.Response, err error) {
-		log.Println("GetAllPortForwarded.OnError()")
-		log.Println("GetAllPortForwarded => error:", err, r.Body)
-	})
+func (router *TechnicolorRouter) GetAllPortForwarded(ctx context.Context) (err error, portsForwarded []PortForwardedWithIndex) {
+	err, rows := router.portForwardingTable().List(ctx)
 
-	router.collector.Visit(url)
+	if err != nil {
+		return err, nil
+	}
 
-	router.collector.OnHTMLDetach("#portforwarding > tbody:nth-child(2)")
+	for i, row := range rows {
+		portsForwarded = append(portsForwarded, PortForwardedWithIndex{
+			Index: i + 1, // index starts from 1
+			Data:  portForwardedFromRow(row),
+		})
+	}
 	return
 }
 
-func (router *TechnicolorRouter) GetPortForwardedByName(name string) (err error, portForwarded PortForwardedWithIndex) {
-	err, portsForwarded := router.GetAllPortForwarded()
+func (router *TechnicolorRouter) GetPortForwardedByName(ctx context.Context, name string) (err error, portForwarded PortForwardedWithIndex) {
+	err, portsForwarded := router.GetAllPortForwarded(ctx)
+
+	if err != nil {
+		return err, PortForwardedWithIndex{Index: -1}
+	}
 
 	for _, portForwarded := range portsForwarded {
 		if portForwarded.Data.Name == name {
@@ -153,7 +165,103 @@ func (router *TechnicolorRouter) GetPortForwardedByName(name string) (err error,
 		}
 	}
 
-	return fmt.Errorf("port forwarding not found"), PortForwardedWithIndex{Index: -1}
+	return ErrPortForwardedNotFound, PortForwardedWithIndex{Index: -1}
+}
+
+// FindPortForwarded looks up the rule matching the given identity in the
+// current router table, the mac address is only used when more than one
+// rule has the same name, protocol and wan port.
+func (router *TechnicolorRouter) FindPortForwarded(ctx context.Context, identity PortForwardedIdentity) (err error, portForwarded PortForwardedWithIndex) {
+	err, portsForwarded := router.GetAllPortForwarded(ctx)
+
+	if err != nil {
+		return err, PortForwardedWithIndex{Index: -1}
+	}
+
+	var matches []PortForwardedWithIndex
+
+	for _, portForwarded := range portsForwarded {
+		if identity.matches(&portForwarded.Data) {
+			matches = append(matches, portForwarded)
+		}
+	}
+
+	if len(matches) > 1 && identity.LanMac != "" {
+		var macMatches []PortForwardedWithIndex
+		for _, portForwarded := range matches {
+			if strings.EqualFold(portForwarded.Data.LanMac, identity.LanMac) {
+				macMatches = append(macMatches, portForwarded)
+			}
+		}
+		matches = macMatches
+	}
+
+	if len(matches) == 0 {
+		return ErrPortForwardedNotFound, PortForwardedWithIndex{Index: -1}
+	}
+
+	if len(matches) > 1 {
+		return fmt.Errorf("%w: %d rules match %s/%s/%d", ErrPortForwardedAmbiguous, len(matches), identity.Name, identity.Protocol, identity.WanPortStart), PortForwardedWithIndex{Index: -1}
+	}
+
+	return nil, matches[0]
+}
+
+// DeletePortForwardedByIdentity resolves the current index of the rule
+// right before deleting it, so that a stale index never removes the
+// wrong row
+func (router *TechnicolorRouter) DeletePortForwardedByIdentity(ctx context.Context, identity PortForwardedIdentity) (err error) {
+	router.writeMu.Lock()
+	defer router.writeMu.Unlock()
+
+	err, portForwarded := router.FindPortForwarded(ctx, identity)
+
+	if err != nil {
+		return err
+	}
+
+	index := portForwarded.Index
+
+	return router.confirmedWrite(ctx, func() error {
+		return router.portForwardingTable().Delete(ctx, index)
+	}, func() (error, bool) {
+		err, portForwarded := router.FindPortForwarded(ctx, identity)
+		if errors.Is(err, ErrPortForwardedNotFound) {
+			return nil, true
+		}
+		index = portForwarded.Index
+		return err, false
+	})
+}
+
+// UpdatePortForwardedByIdentity resolves the current index of the rule
+// right before modifying it
+func (router *TechnicolorRouter) UpdatePortForwardedByIdentity(ctx context.Context, identity PortForwardedIdentity, portForwarding *PortForwarded) (err error) {
+	router.writeMu.Lock()
+	defer router.writeMu.Unlock()
+
+	err, portForwarded := router.FindPortForwarded(ctx, identity)
+
+	if err != nil {
+		return err
+	}
+
+	index := portForwarded.Index
+
+	return router.confirmedWrite(ctx, func() error {
+		return router.portForwardingTable().Modify(ctx, index, portForwardedToRow(portForwarding))
+	}, func() (error, bool) {
+		err, portForwarded := router.FindPortForwarded(ctx, identity)
+		if err != nil {
+			// the identity changed with the update
+			err, portForwarded = router.FindPortForwarded(ctx, portForwarding.Identity())
+		}
+		if err != nil {
+			return err, false
+		}
+		index = portForwarded.Index
+		return nil, portForwarded.Data.sameSettings(portForwarding)
+	})
 }
 
 var PORT_REGEX = regexp.MustCompile(`.*\((?P<port>\d+)\)`)
@@ -172,3 +280,42 @@ func parsePort(portString string) (port int, err error) {
 	}
 	return
 }
+
+var PORT_RANGE_REGEX = regexp.MustCompile(`^(?P<start>\d+)\s*-\s*(?P<end>\d+)$`)
+
+// parsePortRange parses either a single port (`N` or `label(N)`)
+// or a range (`N-M`), a single port is returned with start == end
+func parsePortRange(portString string) (start int, end int, err error) {
+	portString = strings.TrimSpace(portString)
+
+	if matches := PORT_RANGE_REGEX.FindStringSubmatch(portString); matches != nil {
+		start, _ = strconv.Atoi(matches[1])
+		end, _ = strconv.Atoi(matches[2])
+		if end < start {
+			err = fmt.Errorf("invalid port range %s", portString)
+		}
+		return
+	}
+
+	start, err = parsePort(portString)
+	end = start
+	return
+}
+
+// portRange is a port range cell of a table
+type portRange struct {
+	Start int
+	End   int
+}
+
+func (r *portRange) UnmarshalText(text []byte) (err error) {
+	r.Start, r.End, err = parsePortRange(string(text))
+	return
+}
+
+func formatPortRange(start int, end int) string {
+	if end == 0 || end == start {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
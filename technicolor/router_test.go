@@ -0,0 +1,602 @@
+package technicolor_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"terraform-provider-technicolor/technicolor"
+	"terraform-provider-technicolor/technicolortest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T) (*technicolor.TechnicolorRouter, *technicolortest.Gateway) {
+	gateway := technicolortest.NewGateway("admin", "secret")
+	t.Cleanup(gateway.Close)
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	err, isAuthenticated := router.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.True(t, isAuthenticated)
+
+	return router, gateway
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	gateway := technicolortest.NewGateway("admin", "secret")
+	defer gateway.Close()
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	err, isAuthenticated := router.Login(context.Background(), "admin", "wrong")
+	assert.ErrorIs(t, err, technicolor.ErrAuthenticationFailed)
+	assert.False(t, isAuthenticated)
+}
+
+func TestAddPortForwardedDuplicate(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	err := router.AddPortForwarded(context.Background(), &rule)
+	assert.ErrorIs(t, err, technicolor.ErrConflict)
+	assert.Len(t, gateway.PortForwarded(), 1)
+}
+
+func TestReloginAfterSessionExpired(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	gateway.ExpireSessions()
+
+	err, ports := router.GetAllPortForwarded(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, ports, 1)
+	assert.Equal(t, 2, gateway.Logins())
+
+	gateway.ExpireSessions()
+
+	rule.LanIp = "192.168.1.11"
+	require.NoError(t, router.UpdatePortForwardedByIdentity(context.Background(), rule.Identity(), &rule))
+	assert.Equal(t, "192.168.1.11", gateway.PortForwarded()[0].LanIp)
+	assert.Equal(t, 3, gateway.Logins())
+}
+
+func TestReloginBetweenEditAndModify(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	// the session is dropped right after TABLE-EDIT
+	gateway.ExpireSessionsAfter(1)
+
+	rule.Enabled = false
+	require.NoError(t, router.UpdatePortForwarded(context.Background(), 1, &rule))
+	assert.False(t, gateway.PortForwarded()[0].Enabled)
+	assert.Equal(t, 2, gateway.Logins())
+}
+
+func TestReloginConcurrent(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	gateway.ExpireSessions()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err, _ := router.GetAllPortForwarded(context.Background())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	// a single login serves every request that saw the expired session
+	assert.Equal(t, 2, gateway.Logins())
+}
+
+func TestReloginFailed(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	gateway.Password = "changed"
+	gateway.ExpireSessions()
+
+	err, _ := router.GetAllPortForwarded(context.Background())
+	assert.ErrorIs(t, err, technicolor.ErrAuthenticationFailed)
+
+	// without a previous login there are no credentials to login again
+	router = technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	err, _ = router.GetAllPortForwarded(context.Background())
+	assert.ErrorIs(t, err, technicolor.ErrSessionExpired)
+}
+
+func TestPortForwardedLifecycle(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	gateway.SetPortForwarded([]technicolor.PortForwarded{
+		{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"},
+	})
+
+	err := router.AddPortForwarded(context.Background(), &technicolor.PortForwarded{
+		Enabled: true, Name: "rtp", Protocol: "UDP", WanPortStart: 5000, WanPortEnd: 5100, LanPortStart: 5000, LanPortEnd: 5100, LanIp: "192.168.1.20",
+	})
+	require.NoError(t, err)
+
+	err, ports := router.GetAllPortForwarded(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ports, 2)
+	assert.Equal(t, 2, ports[1].Index)
+	assert.Equal(t, "rtp", ports[1].Data.Name)
+	assert.Equal(t, 5000, ports[1].Data.WanPortStart)
+	assert.Equal(t, 5100, ports[1].Data.WanPortEnd)
+	assert.Equal(t, "192.168.1.20", ports[1].Data.LanIp)
+	assert.Equal(t, "02:00:c0:a8:01:14", ports[1].Data.LanMac)
+
+	updated := ports[1].Data
+	updated.Enabled = false
+	err = router.UpdatePortForwardedByIdentity(context.Background(), ports[1].Data.Identity(), &updated)
+	require.NoError(t, err)
+	assert.False(t, gateway.PortForwarded()[1].Enabled)
+
+	err = router.DeletePortForwardedByIdentity(context.Background(), ports[0].Data.Identity())
+	require.NoError(t, err)
+
+	remaining := gateway.PortForwarded()
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "rtp", remaining[0].Name)
+
+	err = router.DeletePortForwardedByIdentity(context.Background(), ports[0].Data.Identity())
+	assert.ErrorIs(t, err, technicolor.ErrPortForwardedNotFound)
+}
+
+func TestFindPortForwardedAmbiguous(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "web", Protocol: "TCP", WanPortStart: 80, WanPortEnd: 80, LanPortStart: 80, LanPortEnd: 80}
+	first, second := rule, rule
+	first.LanIp, first.LanMac = "192.168.1.10", "02:00:c0:a8:01:0a"
+	second.LanIp, second.LanMac = "192.168.1.11", "02:00:c0:a8:01:0b"
+	gateway.SetPortForwarded([]technicolor.PortForwarded{first, second})
+
+	identity := rule.Identity()
+	err, _ := router.FindPortForwarded(context.Background(), identity)
+	assert.ErrorIs(t, err, technicolor.ErrPortForwardedAmbiguous)
+
+	identity.LanMac = second.LanMac
+	err, found := router.FindPortForwarded(context.Background(), identity)
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.Index)
+
+	// only an exact duplicate is refused before reaching the router
+	gateway.SetPortForwarded([]technicolor.PortForwarded{first})
+
+	duplicate := rule
+	duplicate.LanIp = first.LanIp
+	err = router.AddPortForwarded(context.Background(), &duplicate)
+	assert.ErrorIs(t, err, technicolor.ErrPortForwardedExists)
+
+	other := rule
+	other.LanIp = second.LanIp
+	err = router.AddPortForwarded(context.Background(), &other)
+	assert.NotErrorIs(t, err, technicolor.ErrPortForwardedExists)
+}
+
+func TestConcurrentPortForwarded(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	var rules []technicolor.PortForwarded
+	for i := 0; i < 10; i++ {
+		rules = append(rules, technicolor.PortForwarded{
+			Enabled: true, Name: fmt.Sprintf("rule%d", i), Protocol: "TCP", WanPortStart: 1000 + i, WanPortEnd: 1000 + i, LanPortStart: 1000 + i, LanPortEnd: 1000 + i, LanIp: "192.168.1.10",
+		})
+	}
+	gateway.SetPortForwarded(rules)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(rules))
+
+	// delete every even rule while reading the table concurrently,
+	// every deletion shifts the index of the following rules
+	for i := range rules {
+		wg.Add(1)
+		go func(rule technicolor.PortForwarded, even bool) {
+			defer wg.Done()
+			if even {
+				errs <- router.DeletePortForwardedByIdentity(context.Background(), rule.Identity())
+				return
+			}
+			err, ports := router.GetAllPortForwarded(context.Background())
+			if err == nil && len(ports) == 0 {
+				err = fmt.Errorf("empty table")
+			}
+			errs <- err
+		}(rules[i], i%2 == 0)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var remaining []string
+	for _, rule := range gateway.PortForwarded() {
+		remaining = append(remaining, rule.Name)
+	}
+	assert.Equal(t, []string{"rule1", "rule3", "rule5", "rule7", "rule9"}, remaining)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	gateway.SetLatency(time.Second)
+	router.RequestTimeout = 50 * time.Millisecond
+
+	err, _ := router.GetAllPortForwarded(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestContextCancel(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	gateway.SetLatency(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err, _ := router.GetAllPortForwarded(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLogout(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	assert.Equal(t, 1, gateway.Sessions())
+	assert.True(t, router.OwnsSession())
+
+	require.NoError(t, router.Logout(context.Background()))
+	assert.Equal(t, 0, gateway.Sessions())
+
+	// the client does not login again after an explicit logout
+	err, _ := router.GetAllPortForwarded(context.Background())
+	assert.ErrorIs(t, err, technicolor.ErrSessionExpired)
+	assert.Equal(t, 1, gateway.Logins())
+}
+
+func TestResume(t *testing.T) {
+	owner, gateway := newTestRouter(t)
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	err, resumed := router.Resume(context.Background(), owner.SessionID(), "admin", "secret")
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.False(t, router.OwnsSession())
+	assert.Equal(t, owner.SessionID(), router.SessionID())
+
+	err, _ = router.GetAllPortForwarded(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, gateway.Logins())
+	assert.Equal(t, 1, gateway.Sessions())
+}
+
+func TestResumeExpired(t *testing.T) {
+	owner, gateway := newTestRouter(t)
+
+	gateway.ExpireSessions()
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	err, resumed := router.Resume(context.Background(), owner.SessionID(), "admin", "secret")
+	require.NoError(t, err)
+	assert.False(t, resumed)
+	assert.True(t, router.OwnsSession())
+	assert.NotEqual(t, owner.SessionID(), router.SessionID())
+	assert.Equal(t, 2, gateway.Logins())
+}
+
+func TestLoginTLS(t *testing.T) {
+	gateway := technicolortest.NewTLSGateway("admin", "secret")
+	t.Cleanup(gateway.Close)
+
+	otherCertificate := sha256.Sum256([]byte("another certificate"))
+
+	tests := []struct {
+		name    string
+		options technicolor.TLSOptions
+		// the certificate of the gateway is self-signed
+		unknownAuthority bool
+		err              error
+	}{
+		{name: "system CAs", options: technicolor.TLSOptions{}, unknownAuthority: true},
+		{name: "CA certificate", options: technicolor.TLSOptions{CACertificates: gateway.CertificatePEM()}},
+		{name: "fingerprint", options: technicolor.TLSOptions{Fingerprint: gateway.CertificateFingerprint()}},
+		{name: "wrong fingerprint", options: technicolor.TLSOptions{Fingerprint: technicolor.FormatFingerprint(otherCertificate[:])}, err: technicolor.ErrCertificateMismatch},
+		{name: "insecure skip verify", options: technicolor.TLSOptions{InsecureSkipVerify: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err, router := technicolor.NewTechnicolorRouterWithTLS(gateway.Host(), gateway.Port(), test.options)
+			require.NoError(t, err)
+
+			err, isAuthenticated := router.Login(context.Background(), "admin", "secret")
+
+			switch {
+			case test.unknownAuthority:
+				var unknownAuthority x509.UnknownAuthorityError
+				assert.ErrorAs(t, err, &unknownAuthority)
+				assert.False(t, isAuthenticated)
+			case test.err != nil:
+				assert.ErrorIs(t, err, test.err)
+				assert.False(t, isAuthenticated)
+			default:
+				assert.NoError(t, err)
+				assert.True(t, isAuthenticated)
+			}
+		})
+	}
+}
+
+func TestNewTechnicolorRouterWithTLSInvalid(t *testing.T) {
+	err, _ := technicolor.NewTechnicolorRouterWithTLS("192.168.1.1", 443, technicolor.TLSOptions{CACertificates: []byte("not a certificate")})
+	assert.Error(t, err)
+
+	err, _ = technicolor.NewTechnicolorRouterWithTLS("192.168.1.1", 443, technicolor.TLSOptions{Fingerprint: "AB:CD"})
+	assert.Error(t, err)
+
+	// the options are exclusive, none of them is silently ignored
+	fingerprint := technicolor.FormatFingerprint(make([]byte, sha256.Size))
+	for _, options := range []technicolor.TLSOptions{
+		{Fingerprint: fingerprint, InsecureSkipVerify: true},
+		{Fingerprint: fingerprint, CACertificates: []byte("not parsed")},
+		{CACertificates: []byte("not parsed"), InsecureSkipVerify: true},
+	} {
+		err, _ = technicolor.NewTechnicolorRouterWithTLS("192.168.1.1", 443, options)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "only one of")
+		}
+	}
+}
+
+func newTestRouterWithRetries(t *testing.T) (*technicolor.TechnicolorRouter, *technicolortest.Gateway) {
+	router, gateway := newTestRouter(t)
+	router.Retries = 2
+	router.RetryBackoff = time.Millisecond
+	return router, gateway
+}
+
+func TestRetryReads(t *testing.T) {
+	router, gateway := newTestRouterWithRetries(t)
+
+	gateway.Fail(http.MethodGet, 1, technicolortest.FAIL_BEFORE_HANDLING)
+	gateway.Fail(http.MethodGet, 1, technicolortest.FAIL_DROP_CONNECTION)
+
+	err, _ := router.GetAllPortForwarded(context.Background())
+	assert.NoError(t, err)
+
+	gateway.Fail(http.MethodGet, 3, technicolortest.FAIL_BEFORE_HANDLING)
+
+	err, _ = router.GetAllPortForwarded(context.Background())
+	var statusError *technicolor.StatusError
+	require.ErrorAs(t, err, &statusError)
+	assert.Equal(t, http.StatusInternalServerError, statusError.StatusCode)
+}
+
+func TestRetryWrites(t *testing.T) {
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10"}
+
+	for _, kind := range []technicolortest.Failure{technicolortest.FAIL_BEFORE_HANDLING, technicolortest.FAIL_AFTER_HANDLING} {
+		router, gateway := newTestRouterWithRetries(t)
+
+		// the add is sent again only if the router did not apply it
+		gateway.Fail(http.MethodPost, 1, kind)
+		require.NoError(t, router.AddPortForwarded(context.Background(), &rule))
+		assert.Len(t, gateway.PortForwarded(), 1)
+
+		updated := rule
+		updated.LanIp = "192.168.1.11"
+		gateway.Fail(http.MethodPost, 1, kind)
+		require.NoError(t, router.UpdatePortForwardedByIdentity(context.Background(), rule.Identity(), &updated))
+		require.Len(t, gateway.PortForwarded(), 1)
+		assert.Equal(t, "192.168.1.11", gateway.PortForwarded()[0].LanIp)
+
+		gateway.Fail(http.MethodPost, 1, kind)
+		require.NoError(t, router.DeletePortForwardedByIdentity(context.Background(), rule.Identity()))
+		assert.Empty(t, gateway.PortForwarded())
+	}
+}
+
+func TestNoRetryWritesByIndex(t *testing.T) {
+	router, gateway := newTestRouterWithRetries(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	// without an identity there is no way to tell if the delete happened
+	gateway.Fail(http.MethodPost, 1, technicolortest.FAIL_BEFORE_HANDLING)
+	assert.Error(t, router.DeletePortForwarded(context.Background(), 1))
+	assert.Len(t, gateway.PortForwarded(), 1)
+}
+
+func TestMinRequestInterval(t *testing.T) {
+	router, gateway := newTestRouter(t)
+	router.MinRequestInterval = 50 * time.Millisecond
+
+	requests := gateway.Requests()
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		err, _ := router.GetAllPortForwarded(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, requests+4, gateway.Requests())
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestLoginSRPParameters(t *testing.T) {
+	gateway := technicolortest.NewGateway("admin", "secret")
+	t.Cleanup(gateway.Close)
+
+	gateway.HashAlgorithm = technicolor.SHA1
+	gateway.NGType = technicolor.NG_1024
+
+	router := technicolor.NewTechnicolorRouter(gateway.Host(), gateway.Port())
+
+	// the default parameters of the TIM HUB do not match
+	err, isAuthenticated := router.Login(context.Background(), "admin", "secret")
+	assert.ErrorIs(t, err, technicolor.ErrAuthenticationFailed)
+	assert.False(t, isAuthenticated)
+
+	router.HashAlgorithm = technicolor.SHA1
+	router.NGType = technicolor.NG_1024
+
+	err, isAuthenticated = router.Login(context.Background(), "admin", "secret")
+	assert.NoError(t, err)
+	assert.True(t, isAuthenticated)
+
+	router.NGType = technicolor.NG_CUSTOM
+	router.NHex = technicolor.N_CONSTANTS[technicolor.NG_1024].Text(16)
+	router.GHex = "2"
+
+	err, isAuthenticated = router.Login(context.Background(), "admin", "secret")
+	assert.NoError(t, err)
+	assert.True(t, isAuthenticated)
+
+	router.GHex = "1"
+
+	err, _ = router.Login(context.Background(), "admin", "secret")
+	assert.ErrorIs(t, err, technicolor.ErrInvalidSRPParameters)
+}
+
+func TestGetRouterInfo(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	err, info := router.GetRouterInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, gateway.RouterInfo(), info)
+	assert.Equal(t, 26*time.Hour+3*time.Minute+7*time.Second, info.Uptime)
+
+	// the firmware pushed by the ISP shows up after the reboot
+	upgraded := gateway.RouterInfo()
+	upgraded.FirmwareVersion = "2.3.0"
+	upgraded.Uptime = 42 * time.Second
+	gateway.SetRouterInfo(upgraded)
+	gateway.ExpireSessions()
+
+	err, info = router.GetRouterInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "2.3.0", info.FirmwareVersion)
+	assert.Equal(t, 42*time.Second, info.Uptime)
+}
+
+// testRule decodes only some columns of the port forwarding table, as a
+// table of another modal would be
+type testRule struct {
+	Enabled bool   `table:"Status,input"`
+	Name    string `table:"Name"`
+	WanPort string `table:"WAN port"`
+	LanIp   string `table:"Destination IP"`
+}
+
+func (rule testRule) FormData() map[string]string {
+	return map[string]string{
+		"enabled":       map[bool]string{true: "1", false: "0"}[rule.Enabled],
+		"name":          rule.Name,
+		"protocol":      "TCP",
+		"wanport":       rule.WanPort,
+		"lanport":       rule.WanPort,
+		"destinationip": rule.LanIp,
+	}
+}
+
+func TestModalTable(t *testing.T) {
+	router, gateway := newTestRouter(t)
+	ctx := context.Background()
+
+	table := technicolor.NewModalTable[testRule](router, technicolor.TECHNICOLOR_ENDPOINT_PORT_FORWARDING, "portforwarding")
+
+	require.NoError(t, table.Add(ctx, 1, testRule{Enabled: true, Name: "ssh", WanPort: "22", LanIp: "192.168.1.10"}))
+	require.NoError(t, table.Add(ctx, 2, testRule{Enabled: true, Name: "web", WanPort: "80-81", LanIp: "192.168.1.11"}))
+	require.NoError(t, table.Modify(ctx, 1, testRule{Enabled: false, Name: "ssh", WanPort: "2222", LanIp: "192.168.1.10"}))
+
+	err, rules := table.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []testRule{
+		{Enabled: false, Name: "ssh", WanPort: "2222", LanIp: "192.168.1.10"},
+		{Enabled: true, Name: "web", WanPort: "80-81", LanIp: "192.168.1.11"},
+	}, rules)
+	assert.Equal(t, 2222, gateway.PortForwarded()[0].LanPortStart)
+
+	require.NoError(t, table.Delete(ctx, 1))
+
+	err, rules = table.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []testRule{{Enabled: true, Name: "web", WanPort: "80-81", LanIp: "192.168.1.11"}}, rules)
+
+	// a table that is not on the page
+	missing := technicolor.NewModalTable[testRule](router, technicolor.TECHNICOLOR_ENDPOINT_PORT_FORWARDING, "pinholes")
+
+	err, _ = missing.List(ctx)
+	var layoutError *technicolor.LayoutError
+	require.ErrorAs(t, err, &layoutError)
+	assert.Equal(t, "#pinholes", layoutError.Element)
+}
+
+func TestPortForwardedValidation(t *testing.T) {
+	router, gateway := newTestRouter(t)
+
+	rule := technicolor.PortForwarded{Enabled: true, Name: "ssh", Protocol: "TCP", WanPortStart: 22, WanPortEnd: 22, LanPortStart: 22, LanPortEnd: 22, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"}
+	gateway.SetPortForwarded([]technicolor.PortForwarded{rule})
+
+	// the router answers 200 and shows the form again with the messages
+	invalid := technicolor.PortForwarded{Enabled: true, Name: "a-name-longer-than-the-router-accepts", Protocol: "TCP", WanPortStart: 80, WanPortEnd: 80, LanPortStart: 80, LanPortEnd: 80, LanIp: "192.168.1.300"}
+
+	err := router.AddPortForwarded(context.Background(), &invalid)
+	assert.ErrorIs(t, err, technicolor.ErrValidation)
+
+	var validationError *technicolor.ValidationError
+	require.ErrorAs(t, err, &validationError)
+	assert.Equal(t, technicolor.TECHNICOLOR_ENDPOINT_PORT_FORWARDING, validationError.Page)
+	assert.ElementsMatch(t, []technicolor.FieldError{
+		{Field: "name", Message: "Name must be at most 32 characters"},
+		{Field: "destinationip", Message: "Invalid IPv4 address"},
+	}, validationError.Errors)
+	assert.Len(t, gateway.PortForwarded(), 1)
+
+	web := technicolor.PortForwarded{Enabled: true, Name: "web", Protocol: "TCP", WanPortStart: 80, WanPortEnd: 80, LanPortStart: 80, LanPortEnd: 80, LanIp: "192.168.1.10"}
+	require.NoError(t, router.AddPortForwarded(context.Background(), &web))
+
+	// moving the rule on the port of another one
+	web.WanPortStart, web.WanPortEnd = 20, 30
+
+	err = router.UpdatePortForwardedByIdentity(context.Background(), technicolor.PortForwardedIdentity{Name: "web", Protocol: "TCP", WanPortStart: 80}, &web)
+	require.ErrorAs(t, err, &validationError)
+	assert.Equal(t, []technicolor.FieldError{{Field: "wanport", Message: "Port already used by ssh"}}, validationError.Errors)
+	assert.Equal(t, 80, gateway.PortForwarded()[1].WanPortStart)
+}
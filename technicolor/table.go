@@ -0,0 +1,235 @@
+package technicolor
+
+import (
+	"encoding"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TABLE_TAG is the struct tag mapping a field to a column of a table.
+// The tag holds the header of the column, alternatives are separated by
+// `|`, followed by options:
+//
+//	Enabled bool      `table:"Status,input"`
+//	WanPort portRange `table:"WAN port|wanport"`
+//	LanMac  string    `table:"Destination MAC,optional"`
+//
+// A header matches the text of a th element or its data-name attribute,
+// ignoring case and spaces. The options are:
+//   - input: the value of the input of the cell is read instead of its text
+//   - optional: the field is left empty when the column is missing
+//   - position=N: the column read when the headers do not match, the table
+//     is then read by position only if every field has one
+//
+// Fields can be strings, ints, bools or implement encoding.TextUnmarshaler.
+const TABLE_TAG = "table"
+
+type tableColumn struct {
+	field    int
+	headers  []string
+	input    bool
+	optional bool
+	// the position of the column in the known layout, -1 when not given
+	position int
+	// the position of the column in the table, -1 when missing
+	index int
+}
+
+// ParseTable reads the table found by selector in document into rows, a
+// pointer to a slice of tagged structs, one element per row of the body.
+// A missing table or column is reported as a LayoutError, a cell that
+// cannot be parsed as ErrUnexpectedLayout.
+func ParseTable(page string, document *goquery.Document, selector string, rows interface{}) error {
+	slice := reflect.ValueOf(rows)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice || slice.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ParseTable needs a pointer to a slice of structs, got %T", rows)
+	}
+
+	rowType := slice.Elem().Type().Elem()
+
+	err, columns := tableColumns(rowType)
+	if err != nil {
+		return err
+	}
+
+	table := document.Find(selector).First()
+
+	if table.Length() == 0 {
+		return &LayoutError{Page: page, Element: selector}
+	}
+
+	headers := table.Find("thead th")
+	if headers.Length() == 0 {
+		headers = table.Find("tr").First().Find("th")
+	}
+
+	positions := map[string]int{}
+	headers.Each(func(i int, header *goquery.Selection) {
+		for _, key := range []string{header.Text(), header.AttrOr("data-name", "")} {
+			key = normalizeHeader(key)
+			if _, ok := positions[key]; key != "" && !ok {
+				positions[key] = i
+			}
+		}
+	})
+
+	var missing *LayoutError
+	for i := range columns {
+		column := &columns[i]
+		column.index = -1
+		for _, header := range column.headers {
+			if index, ok := positions[normalizeHeader(header)]; ok {
+				column.index = index
+				break
+			}
+		}
+		if column.index < 0 && !column.optional && missing == nil {
+			missing = &LayoutError{Page: page, Element: fmt.Sprintf("column %q of %s", column.headers[0], selector)}
+		}
+	}
+
+	if missing != nil {
+		if !hasPositions(columns) {
+			return missing
+		}
+		// the headers of the firmware are unknown, fall back to the known
+		// layout of the table
+		log.Printf("[WARN] %v, reading %s by position", missing, selector)
+		for i := range columns {
+			columns[i].index = columns[i].position
+		}
+	}
+
+	result := reflect.MakeSlice(slice.Elem().Type(), 0, 0)
+
+	table.Find("tbody > tr").EachWithBreak(func(_ int, tr *goquery.Selection) bool {
+		cells := tr.Children().Filter("td")
+		// the header row when the table has no thead
+		if cells.Length() == 0 {
+			return true
+		}
+
+		row := reflect.New(rowType).Elem()
+
+		for _, column := range columns {
+			if column.index < 0 {
+				continue
+			}
+
+			if column.index >= cells.Length() {
+				if missing != nil && column.optional {
+					continue
+				}
+				err = &LayoutError{Page: page, Element: fmt.Sprintf("cell %q in row %d of %s", column.headers[0], result.Len()+1, selector)}
+				return false
+			}
+
+			cell := cells.Eq(column.index)
+
+			var value string
+			if column.input {
+				value = cell.Find("input").AttrOr("value", "")
+			} else {
+				value = strings.TrimSpace(cell.Text())
+			}
+
+			if err = setTableField(row.Field(column.field), value); err != nil {
+				err = fmt.Errorf("%w: %s: row %d column %q: %v", ErrUnexpectedLayout, page, result.Len()+1, column.headers[0], err)
+				return false
+			}
+		}
+
+		result = reflect.Append(result, row)
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+
+	slice.Elem().Set(result)
+	return nil
+}
+
+func tableColumns(rowType reflect.Type) (err error, columns []tableColumn) {
+	for i := 0; i < rowType.NumField(); i++ {
+		tag, ok := rowType.Field(i).Tag.Lookup(TABLE_TAG)
+		if !ok || tag == "-" {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		column := tableColumn{field: i, headers: strings.Split(options[0], "|"), position: -1}
+
+		for _, option := range options[1:] {
+			switch {
+			case option == "input":
+				column.input = true
+			case option == "optional":
+				column.optional = true
+			case strings.HasPrefix(option, "position="):
+				position, err := strconv.Atoi(strings.TrimPrefix(option, "position="))
+				if err != nil || position < 0 {
+					return fmt.Errorf("invalid table option %q on %s.%s", option, rowType.Name(), rowType.Field(i).Name), nil
+				}
+				column.position = position
+			default:
+				return fmt.Errorf("unknown table option %q on %s.%s", option, rowType.Name(), rowType.Field(i).Name), nil
+			}
+		}
+
+		columns = append(columns, column)
+	}
+	return
+}
+
+// hasPositions tells if the table can be read by position, every column
+// must have one
+func hasPositions(columns []tableColumn) bool {
+	for _, column := range columns {
+		if column.position < 0 {
+			return false
+		}
+	}
+	return len(columns) > 0
+}
+
+func setTableField(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		// a switch without value is off, like the web UI shows it
+		if value == "" {
+			field.SetBool(false)
+			break
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(header), " "))
+}
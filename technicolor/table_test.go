@@ -0,0 +1,142 @@
+package technicolor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDocument(t *testing.T, html string) *goquery.Document {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	require.NoError(t, err)
+	return document
+}
+
+func TestParseTable(t *testing.T) {
+	// a firmware adding a column and moving the mac before the ip
+	document := newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Status</th><th> Name </th><th>Comment</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination</th><th>Destination MAC</th><th data-name="destinationip">IP</th></tr></thead>
+<tbody>
+<tr><td><input type="hidden" value="1"></td><td>ssh</td><td>admin</td><td>TCP</td><td>2222</td><td>SSH (22)</td><td>nas</td><td>02:00:c0:a8:01:0a</td><td>192.168.1.10</td></tr>
+<tr><td><input type="hidden" value="0"></td><td>rtp</td><td></td><td>UDP</td><td>5000-5010</td><td>5000-5010</td><td>phone</td><td>02:00:c0:a8:01:0b</td><td>192.168.1.11</td></tr>
+</tbody>
+</table>`)
+
+	var rows []portForwardedRow
+	require.NoError(t, ParseTable("test", document, "#portforwarding", &rows))
+
+	assert.Equal(t, []portForwardedRow{
+		{Enabled: true, Name: "ssh", Protocol: "TCP", WanPort: portRange{2222, 2222}, LanPort: portRange{22, 22}, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"},
+		{Enabled: false, Name: "rtp", Protocol: "UDP", WanPort: portRange{5000, 5010}, LanPort: portRange{5000, 5010}, LanIp: "192.168.1.11", LanMac: "02:00:c0:a8:01:0b"},
+	}, rows)
+}
+
+func TestParseTableOptionalColumn(t *testing.T) {
+	document := newTestDocument(t, `<table id="portforwarding">
+<tr><th>Status</th><th>Name</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination IP</th></tr>
+<tr><td><input value="1"></td><td>web</td><td>TCP</td><td>80</td><td>8080</td><td>192.168.1.10</td></tr>
+</table>`)
+
+	var rows []portForwardedRow
+	require.NoError(t, ParseTable("test", document, "#portforwarding", &rows))
+
+	require.Len(t, rows, 1)
+	assert.Equal(t, "web", rows[0].Name)
+	assert.Equal(t, "", rows[0].LanMac)
+}
+
+func TestParseTableEmptySwitch(t *testing.T) {
+	document := newTestDocument(t, `<table id="portforwarding">
+<tr><th>Status</th><th>Name</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination IP</th></tr>
+<tr><td><input value=""></td><td>web</td><td>TCP</td><td>80</td><td>8080</td><td>192.168.1.10</td></tr>
+<tr><td><input></td><td>ssh</td><td>TCP</td><td>22</td><td>22</td><td>192.168.1.10</td></tr>
+</table>`)
+
+	var rows []portForwardedRow
+	require.NoError(t, ParseTable("test", document, "#portforwarding", &rows))
+
+	require.Len(t, rows, 2)
+	assert.False(t, rows[0].Enabled)
+	assert.False(t, rows[1].Enabled)
+}
+
+func TestParseTableLayoutChanged(t *testing.T) {
+	var rows []portForwardedRow
+
+	err := ParseTable("test", newTestDocument(t, `<table id="other"></table>`), "#portforwarding", &rows)
+	assert.ErrorIs(t, err, ErrUnexpectedLayout)
+
+	// a renamed column of a table without known positions
+	var byHeader []struct {
+		Name    string    `table:"Name"`
+		WanPort portRange `table:"WAN port"`
+	}
+	err = ParseTable("test", newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Status</th><th>Name</th><th>Protocol</th><th>External port</th><th>LAN port</th><th>Destination IP</th></tr></thead>
+<tbody></tbody>
+</table>`), "#portforwarding", &byHeader)
+	var layoutError *LayoutError
+	require.ErrorAs(t, err, &layoutError)
+	assert.Equal(t, `column "WAN port" of #portforwarding`, layoutError.Element)
+
+	// a row with less cells than the header
+	err = ParseTable("test", newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Status</th><th>Name</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination IP</th></tr></thead>
+<tbody><tr><td><input value="1"></td><td>web</td><td>TCP</td></tr></tbody>
+</table>`), "#portforwarding", &rows)
+	assert.ErrorIs(t, err, ErrUnexpectedLayout)
+
+	// a cell that cannot be parsed
+	err = ParseTable("test", newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Status</th><th>Name</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination IP</th></tr></thead>
+<tbody><tr><td><input value="1"></td><td>web</td><td>TCP</td><td>http</td><td>80</td><td>192.168.1.10</td></tr></tbody>
+</table>`), "#portforwarding", &rows)
+	assert.ErrorIs(t, err, ErrUnexpectedLayout)
+	assert.Nil(t, rows)
+}
+
+func TestParseTablePositionalFallback(t *testing.T) {
+	// a firmware with translated headers keeps the TIM HUB layout
+	document := newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Stato</th><th>Nome</th><th>Protocollo</th><th>Porta WAN</th><th>Porta LAN</th><th>Destinazione</th><th>IP</th><th>MAC</th><th></th></tr></thead>
+<tbody>
+<tr><td><input type="hidden" value="1"></td><td>ssh</td><td>TCP</td><td>2222</td><td>22</td><td>nas</td><td>192.168.1.10</td><td>02:00:c0:a8:01:0a</td><td></td></tr>
+<tr><td><input type="hidden" value="0"></td><td>rtp</td><td>UDP</td><td>5000-5010</td><td>5000-5010</td><td>phone</td><td>192.168.1.11</td></tr>
+</tbody>
+</table>`)
+
+	var rows []portForwardedRow
+	require.NoError(t, ParseTable("test", document, "#portforwarding", &rows))
+
+	assert.Equal(t, []portForwardedRow{
+		{Enabled: true, Name: "ssh", Protocol: "TCP", WanPort: portRange{2222, 2222}, LanPort: portRange{22, 22}, LanIp: "192.168.1.10", LanMac: "02:00:c0:a8:01:0a"},
+		{Enabled: false, Name: "rtp", Protocol: "UDP", WanPort: portRange{5000, 5010}, LanPort: portRange{5000, 5010}, LanIp: "192.168.1.11"},
+	}, rows)
+
+	// a row too short for the layout
+	err := ParseTable("test", newTestDocument(t, `<table id="portforwarding">
+<thead><tr><th>Stato</th><th>Nome</th></tr></thead>
+<tbody><tr><td><input value="1"></td><td>web</td></tr></tbody>
+</table>`), "#portforwarding", &rows)
+	assert.ErrorIs(t, err, ErrUnexpectedLayout)
+}
+
+func TestParseTableInvalidRows(t *testing.T) {
+	document := newTestDocument(t, `<table id="t"></table>`)
+
+	var notSlice portForwardedRow
+	assert.Error(t, ParseTable("test", document, "#t", &notSlice))
+
+	var unknownOption []struct {
+		Name string `table:"Name,bold"`
+	}
+	assert.Error(t, ParseTable("test", document, "#t", &unknownOption))
+
+	var invalidPosition []struct {
+		Name string `table:"Name,position=first"`
+	}
+	assert.Error(t, ParseTable("test", document, "#t", &invalidPosition))
+}
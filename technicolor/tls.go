@@ -0,0 +1,112 @@
+package technicolor
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TLSOptions configures how the certificate of a router serving the web UI
+// over HTTPS is verified. By default it has to be signed by a CA trusted by
+// the system, which is rarely the case for the self-signed certificate of
+// the router. The options are exclusive, each one is a different way to
+// trust the certificate.
+type TLSOptions struct {
+	// PEM encoded CA certificates trusted on top of the system ones
+	CACertificates []byte
+	// SHA-256 fingerprint of the router certificate, hex encoded with or
+	// without colons. When set only that certificate is accepted, whoever
+	// signed it.
+	Fingerprint string
+	// accept any certificate, the connection is encrypted but not authenticated
+	InsecureSkipVerify bool
+}
+
+func newTLSConfig(address string, options TLSOptions) (err error, config *tls.Config) {
+	if conflicts := options.conflicts(); len(conflicts) > 1 {
+		return fmt.Errorf("only one of %s can be set", strings.Join(conflicts, ", ")), nil
+	}
+
+	config = &tls.Config{
+		ServerName: address,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(options.CACertificates) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(options.CACertificates) {
+			return fmt.Errorf("no valid PEM certificate found in the CA certificates"), nil
+		}
+		config.RootCAs = pool
+	}
+
+	if options.Fingerprint != "" {
+		err, fingerprint := ParseFingerprint(options.Fingerprint)
+		if err != nil {
+			return err, nil
+		}
+
+		// the chain is not verified, the pinned certificate is trusted as is
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrCertificateMismatch
+			}
+
+			actual := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(actual[:], fingerprint) != 1 {
+				return fmt.Errorf("%w: got %s", ErrCertificateMismatch, FormatFingerprint(actual[:]))
+			}
+			return nil
+		}
+	} else if options.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+
+	return nil, config
+}
+
+// conflicts returns the names of the options that are set, more than one
+// is a conflict
+func (options TLSOptions) conflicts() (names []string) {
+	if len(options.CACertificates) > 0 {
+		names = append(names, "CACertificates")
+	}
+	if options.Fingerprint != "" {
+		names = append(names, "Fingerprint")
+	}
+	if options.InsecureSkipVerify {
+		names = append(names, "InsecureSkipVerify")
+	}
+	return
+}
+
+// ParseFingerprint decodes a SHA-256 fingerprint like the ones shown by
+// browsers and openssl, with or without colons
+func ParseFingerprint(fingerprint string) (err error, sum []byte) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", "")
+
+	sum, err = hex.DecodeString(cleaned)
+	if err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 fingerprint %q", fingerprint), nil
+	}
+	return nil, sum
+}
+
+// FormatFingerprint encodes a SHA-256 fingerprint as uppercase hex pairs
+// separated by colons
+func FormatFingerprint(sum []byte) string {
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":")
+}
@@ -0,0 +1,720 @@
+// Package technicolortest provides an in-process fake TIM HUB gateway,
+// to exercise the technicolor client and the provider without a router.
+package technicolortest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"terraform-provider-technicolor/technicolor"
+	"time"
+)
+
+const sessionCookie = technicolor.SESSION_COOKIE
+
+// Gateway is a fake TIM HUB web server. It serves the CSRF token, the SRP
+// handshake on /authenticate and the port forwarding table of
+// wanservices-modal.lp, which can be changed with TABLE-ADD, TABLE-EDIT,
+// TABLE-MODIFY and TABLE-DELETE like on the real router, and the gateway
+// overview of gateway-modal.lp.
+type Gateway struct {
+	Server   *httptest.Server
+	Username string
+	Password string
+	// the SRP parameters, SHA256 with the 2048 bits group like the TIM HUB
+	HashAlgorithm int
+	NGType        int
+	NHex          string
+	GHex          string
+
+	mu             sync.Mutex
+	sessions       map[string]*session
+	portForwarding []technicolor.PortForwarded
+	info           technicolor.RouterInfo
+	latency        time.Duration
+	logins         int
+	expireAfter    int
+	requests       int
+	failures       []failure
+}
+
+// Failure is how the gateway fails a request, like the lua web server of
+// the router does under load
+type Failure int
+
+const (
+	// answer 500 without handling the request
+	FAIL_BEFORE_HANDLING Failure = iota
+	// handle the request and then answer 500
+	FAIL_AFTER_HANDLING
+	// close the connection without answering
+	FAIL_DROP_CONNECTION
+)
+
+type failure struct {
+	method string
+	kind   Failure
+}
+
+type session struct {
+	id            string
+	csrfToken     string
+	authenticated bool
+	srp           *technicolor.CryptoVerifier
+	editIndex     int
+}
+
+// NewGateway starts a fake gateway accepting the given credentials,
+// Close must be called to shut it down.
+func NewGateway(username string, password string) *Gateway {
+	gateway := newGateway(username, password)
+	gateway.Server = httptest.NewServer(http.HandlerFunc(gateway.serveHTTP))
+	return gateway
+}
+
+// NewTLSGateway starts a fake gateway serving the web UI over HTTPS with a
+// self-signed certificate, see CertificatePEM and CertificateFingerprint
+func NewTLSGateway(username string, password string) *Gateway {
+	gateway := newGateway(username, password)
+	gateway.Server = httptest.NewUnstartedServer(http.HandlerFunc(gateway.serveHTTP))
+	// the handshakes refused by the tests are expected, do not log them
+	gateway.Server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	gateway.Server.StartTLS()
+	return gateway
+}
+
+func newGateway(username string, password string) *Gateway {
+	return &Gateway{
+		Username:      username,
+		Password:      password,
+		HashAlgorithm: technicolor.SHA256,
+		NGType:        technicolor.NG_2048,
+		sessions:      map[string]*session{},
+		info: technicolor.RouterInfo{
+			ProductName:     "DGA4132",
+			HardwareVersion: "VBNT-S",
+			FirmwareVersion: "2.2.1",
+			SerialNumber:    "CP1234SA0AB",
+			MacAddress:      "D4:35:1D:00:00:01",
+			Uptime:          26*time.Hour + 3*time.Minute + 7*time.Second,
+		},
+	}
+}
+
+// CertificatePEM returns the certificate of a TLS gateway, to be trusted as a CA
+func (gateway *Gateway) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: gateway.Server.Certificate().Raw})
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of the certificate
+// of a TLS gateway
+func (gateway *Gateway) CertificateFingerprint() string {
+	sum := sha256.Sum256(gateway.Server.Certificate().Raw)
+	return technicolor.FormatFingerprint(sum[:])
+}
+
+func (gateway *Gateway) Close() {
+	gateway.Server.Close()
+}
+
+// Host returns the address to pass to technicolor.NewTechnicolorRouter
+func (gateway *Gateway) Host() string {
+	host, _, _ := net.SplitHostPort(gateway.Server.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port to pass to technicolor.NewTechnicolorRouter
+func (gateway *Gateway) Port() int {
+	_, port, _ := net.SplitHostPort(gateway.Server.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// PortForwarded returns a copy of the current port forwarding table
+func (gateway *Gateway) PortForwarded() []technicolor.PortForwarded {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	return append([]technicolor.PortForwarded(nil), gateway.portForwarding...)
+}
+
+// SetPortForwarded replaces the port forwarding table, as if the rules
+// were changed from the web UI
+func (gateway *Gateway) SetPortForwarded(portForwarding []technicolor.PortForwarded) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.portForwarding = append([]technicolor.PortForwarded(nil), portForwarding...)
+}
+
+// RouterInfo returns the identity shown by the gateway overview
+func (gateway *Gateway) RouterInfo() technicolor.RouterInfo {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	return gateway.info
+}
+
+// SetRouterInfo changes the identity shown by the gateway overview, as
+// after a firmware upgrade or a reboot
+func (gateway *Gateway) SetRouterInfo(info technicolor.RouterInfo) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.info = info
+}
+
+// ExpireSessions drops every session, as the router does after the idle
+// timeout or when the admin logs in from somewhere else
+func (gateway *Gateway) ExpireSessions() {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.sessions = map[string]*session{}
+}
+
+// ExpireSessionsAfter drops every session once the given number of requests
+// has been served, to expire a session in the middle of an operation
+func (gateway *Gateway) ExpireSessionsAfter(requests int) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.expireAfter = requests
+}
+
+// Fail makes the next count requests with the given method fail, any
+// method if it is empty
+func (gateway *Gateway) Fail(method string, count int, kind Failure) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	for i := 0; i < count; i++ {
+		gateway.failures = append(gateway.failures, failure{method: method, kind: kind})
+	}
+}
+
+// Requests returns the number of requests received
+func (gateway *Gateway) Requests() int {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	return gateway.requests
+}
+
+// Sessions returns the number of authenticated sessions still open
+func (gateway *Gateway) Sessions() int {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	count := 0
+	for _, sess := range gateway.sessions {
+		if sess.authenticated {
+			count++
+		}
+	}
+	return count
+}
+
+// Logins returns the number of successful logins
+func (gateway *Gateway) Logins() int {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	return gateway.logins
+}
+
+// SetLatency delays every response, to simulate a slow or hung router
+func (gateway *Gateway) SetLatency(latency time.Duration) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.latency = latency
+}
+
+func (gateway *Gateway) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	gateway.mu.Lock()
+	latency := gateway.latency
+	gateway.mu.Unlock()
+
+	select {
+	case <-time.After(latency):
+	case <-r.Context().Done():
+		return
+	}
+
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.requests++
+
+	if len(gateway.failures) > 0 && (gateway.failures[0].method == "" || gateway.failures[0].method == r.Method) {
+		kind := gateway.failures[0].kind
+		gateway.failures = gateway.failures[1:]
+
+		switch kind {
+		case FAIL_BEFORE_HANDLING:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		case FAIL_AFTER_HANDLING:
+			gateway.handle(httptest.NewRecorder(), r)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		case FAIL_DROP_CONNECTION:
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		}
+	}
+
+	gateway.handle(w, r)
+}
+
+func (gateway *Gateway) handle(w http.ResponseWriter, r *http.Request) {
+	sess := gateway.session(w, r)
+
+	// the client joins endpoints with an extra slash, the router does not care
+	switch path.Clean(r.URL.Path) {
+	case "/":
+		gateway.serveHome(w, r, sess)
+	case "/login.lp":
+		gateway.serveLogin(w, sess)
+	case technicolor.TECHNICOLOR_ENDPOINT_AUTHENTICATE:
+		gateway.serveAuthenticate(w, r, sess)
+	case technicolor.TECHNICOLOR_ENDPOINT_PORT_FORWARDING:
+		gateway.servePortForwarding(w, r, sess)
+	case technicolor.TECHNICOLOR_ENDPOINT_GATEWAY:
+		gateway.serveGateway(w, sess)
+	default:
+		http.NotFound(w, r)
+	}
+
+	if gateway.expireAfter > 0 {
+		gateway.expireAfter--
+		if gateway.expireAfter == 0 {
+			gateway.sessions = map[string]*session{}
+		}
+	}
+}
+
+func (gateway *Gateway) session(w http.ResponseWriter, r *http.Request) *session {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if sess, ok := gateway.sessions[cookie.Value]; ok {
+			return sess
+		}
+	}
+	return gateway.newSession(w)
+}
+
+func (gateway *Gateway) newSession(w http.ResponseWriter) *session {
+	id := randomHex(16)
+	sess := &session{id: id, csrfToken: randomHex(32)}
+	gateway.sessions[id] = sess
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/"})
+	return sess
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<meta name="CSRFtoken" content="{{.}}">
+<title>Login</title>
+</head>
+<body><form id="login"><input id="srp_username" type="text"><input id="srp_password" type="password"></form></body>
+</html>
+`))
+
+func (gateway *Gateway) serveLogin(w http.ResponseWriter, sess *session) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	loginTemplate.Execute(w, sess.csrfToken)
+}
+
+var homeTemplate = template.Must(template.New("home").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<meta http-equiv="X-UA-Compatible" content="IE=edge">
+<meta name="CSRFtoken" content="{{.}}">
+<title>TIM HUB</title>
+</head>
+<body><div class="header"><a id="signout" href="#">Sign out</a></div></body>
+</html>
+`))
+
+// serveHome serves the home page to an authenticated session and the login
+// page otherwise, a POST with do_signout closes the session
+func (gateway *Gateway) serveHome(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.Method == http.MethodPost && r.PostFormValue("do_signout") == "1" {
+		if r.PostFormValue("CSRFtoken") != sess.csrfToken {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		delete(gateway.sessions, sess.id)
+		gateway.serveLogin(w, gateway.newSession(w))
+		return
+	}
+
+	if !sess.authenticated {
+		gateway.serveLogin(w, sess)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	homeTemplate.Execute(w, sess.csrfToken)
+}
+
+func (gateway *Gateway) serveAuthenticate(w http.ResponseWriter, r *http.Request, sess *session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.PostFormValue("CSRFtoken") != sess.csrfToken {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.PostFormValue("A") != "" {
+		A, err := hex.DecodeString(r.PostFormValue("A"))
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed"})
+			return
+		}
+
+		// unknown users still get a challenge, the proof will not match
+		password := gateway.Password
+		if r.PostFormValue("I") != gateway.Username {
+			password = randomHex(16)
+		}
+
+		err, salt, verifier := technicolor.CreateSaltedVerificationKey(r.PostFormValue("I"), password, gateway.HashAlgorithm, gateway.NGType, gateway.NHex, gateway.GHex)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err, sess.srp = technicolor.NewCryptoVerifier(r.PostFormValue("I"), salt, verifier, A, gateway.HashAlgorithm, gateway.NGType, gateway.NHex, gateway.GHex)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s, B := sess.srp.GetChallenge()
+		if s == nil || B == nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"s": hex.EncodeToString(s),
+			"B": hex.EncodeToString(B),
+		})
+		return
+	}
+
+	M, err := hex.DecodeString(r.PostFormValue("M"))
+	if err != nil || sess.srp == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed"})
+		return
+	}
+
+	HAMK := sess.srp.VerifySession(M)
+	sess.srp = nil
+
+	if HAMK == nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed"})
+		return
+	}
+
+	// a new session is issued on login, the client has to refresh the token
+	authenticated := gateway.newSession(w)
+	authenticated.authenticated = true
+	gateway.logins++
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"M": hex.EncodeToString(HAMK),
+	})
+}
+
+var portForwardingTemplate = template.Must(template.New("portforwarding").Parse(`<!DOCTYPE html>
+<div class="modal-header"><h2>WAN services</h2></div>
+<div class="modal-body">
+<form class="form-horizontal" method="post" action="modals/wanservices-modal.lp">
+<table id="portforwarding" class="table table-striped">
+<thead><tr><th>Status</th><th>Name</th><th>Protocol</th><th>WAN port</th><th>LAN port</th><th>Destination</th><th>Destination IP</th><th>Destination MAC</th><th></th></tr></thead>
+<tbody>
+{{range .Rows}}<tr><td><div class="switch"><input type="hidden" name="enabled" value="{{if .Enabled}}1{{else}}0{{end}}"></div></td><td>{{.Name}}</td><td>{{.Protocol}}</td><td>{{.WanPort}}</td><td>{{.LanPort}}</td><td>{{.Device}}</td><td>{{.LanIp}}</td><td>{{.LanMac}}</td><td><div class="btn-table-edit"></div><div class="btn-table-delete"></div></td></tr>
+{{end}}{{if .Form}}<tr class="line-edit">{{range .Form}}<td><div class="control-group{{if .Error}} error{{end}}"><input type="text" name="{{.Name}}" value="{{.Value}}">{{if .Error}}<span class="help-inline">{{.Error}}</span>{{end}}</div></td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+</form>
+</div>
+`))
+
+type portForwardingPage struct {
+	Rows []portForwardingRow
+	// the submitted form, shown again when a value is refused
+	Form []formField
+}
+
+type formField struct {
+	Name  string
+	Value string
+	Error string
+}
+
+type portForwardingRow struct {
+	Enabled  bool
+	Name     string
+	Protocol string
+	WanPort  string
+	LanPort  string
+	Device   string
+	LanIp    string
+	LanMac   string
+}
+
+func (gateway *Gateway) servePortForwarding(w http.ResponseWriter, r *http.Request, sess *session) {
+	if !sess.authenticated {
+		// the router answers with the login page when the session is not valid
+		gateway.serveLogin(w, sess)
+		return
+	}
+
+	var page portForwardingPage
+
+	if r.Method == http.MethodPost {
+		if r.PostFormValue("CSRFtoken") != sess.csrfToken {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		if r.PostFormValue("tableid") != "portforwarding" {
+			http.Error(w, "unknown table", http.StatusBadRequest)
+			return
+		}
+
+		status, fieldErrors, err := gateway.applyAction(r, sess)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		// like the router, refused values are answered with 200 and
+		// the form showing a message next to each of them
+		if len(fieldErrors) > 0 {
+			for _, name := range []string{"enabled", "name", "protocol", "wanport", "lanport", "destinationip"} {
+				page.Form = append(page.Form, formField{Name: name, Value: r.PostFormValue(name), Error: fieldErrors[name]})
+			}
+		}
+	}
+
+	for _, p := range gateway.portForwarding {
+		page.Rows = append(page.Rows, portForwardingRow{
+			Enabled:  p.Enabled,
+			Name:     p.Name,
+			Protocol: p.Protocol,
+			WanPort:  formatPortRange(p.WanPortStart, p.WanPortEnd),
+			LanPort:  formatPortRange(p.LanPortStart, p.LanPortEnd),
+			Device:   "Unknown-" + strings.ReplaceAll(p.LanMac, ":", ""),
+			LanIp:    p.LanIp,
+			LanMac:   p.LanMac,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	portForwardingTemplate.Execute(w, page)
+}
+
+var gatewayTemplate = template.Must(template.New("gateway").Parse(`<!DOCTYPE html>
+<div class="modal-header"><h2>Gateway</h2></div>
+<div class="modal-body">
+<form class="form-horizontal">
+<fieldset>
+<legend>Global Information</legend>
+{{range .}}<div class="control-group"><label class="control-label">{{.Label}}</label><div class="controls"><span class="span4 simple-desc">{{.Value}}</span></div></div>
+{{end}}</fieldset>
+</form>
+</div>
+`))
+
+type gatewayRow struct {
+	Label string
+	Value string
+}
+
+func (gateway *Gateway) serveGateway(w http.ResponseWriter, sess *session) {
+	if !sess.authenticated {
+		gateway.serveLogin(w, sess)
+		return
+	}
+
+	info := gateway.info
+	uptime := info.Uptime / time.Second
+
+	rows := []gatewayRow{
+		{"Product Vendor", "Technicolor"},
+		{technicolor.ROUTER_INFO_PRODUCT_NAME, info.ProductName},
+		{"Software Version", info.FirmwareVersion},
+		{technicolor.ROUTER_INFO_FIRMWARE_VERSION, info.FirmwareVersion},
+		{technicolor.ROUTER_INFO_HARDWARE_VERSION, info.HardwareVersion},
+		{technicolor.ROUTER_INFO_SERIAL_NUMBER, info.SerialNumber},
+		{technicolor.ROUTER_INFO_MAC_ADDRESS, info.MacAddress},
+		{technicolor.ROUTER_INFO_UPTIME, fmt.Sprintf("%d days %d hours %d min %d sec", uptime/86400, uptime%86400/3600, uptime%3600/60, uptime%60)},
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	gatewayTemplate.Execute(w, rows)
+}
+
+// applyAction changes the table as asked by the form, the values refused
+// by the validation are returned by form parameter
+func (gateway *Gateway) applyAction(r *http.Request, sess *session) (int, map[string]string, error) {
+	action := r.PostFormValue("action")
+
+	index, err := strconv.Atoi(r.PostFormValue("index"))
+	if err != nil && action != "TABLE-ADD" {
+		return http.StatusBadRequest, nil, fmt.Errorf("invalid index")
+	}
+
+	switch action {
+	case "TABLE-ADD":
+		portForwarded, fieldErrors := gateway.portForwardedFromForm(r, 0)
+		if len(fieldErrors) > 0 {
+			return http.StatusOK, fieldErrors, nil
+		}
+		gateway.portForwarding = append(gateway.portForwarding, portForwarded)
+	case "TABLE-EDIT":
+		if index < 1 || index > len(gateway.portForwarding) {
+			return http.StatusBadRequest, nil, fmt.Errorf("index out of range")
+		}
+		sess.editIndex = index
+	case "TABLE-MODIFY":
+		if index < 1 || index > len(gateway.portForwarding) || index != sess.editIndex {
+			return http.StatusBadRequest, nil, fmt.Errorf("row %d is not being edited", index)
+		}
+		// the row stays in edit mode until the values are accepted
+		portForwarded, fieldErrors := gateway.portForwardedFromForm(r, index)
+		if len(fieldErrors) > 0 {
+			return http.StatusOK, fieldErrors, nil
+		}
+		gateway.portForwarding[index-1] = portForwarded
+		sess.editIndex = 0
+	case "TABLE-DELETE":
+		if index < 1 || index > len(gateway.portForwarding) {
+			return http.StatusBadRequest, nil, fmt.Errorf("index out of range")
+		}
+		gateway.portForwarding = append(gateway.portForwarding[:index-1], gateway.portForwarding[index:]...)
+	default:
+		return http.StatusBadRequest, nil, fmt.Errorf("unknown action %s", action)
+	}
+
+	return http.StatusOK, nil, nil
+}
+
+// the longest rule name accepted by the web UI
+const maxNameLength = 32
+
+// portForwardedFromForm reads and validates the rule of the form, index is
+// the row being edited, which can keep its own wan ports
+func (gateway *Gateway) portForwardedFromForm(r *http.Request, index int) (portForwarded technicolor.PortForwarded, fieldErrors map[string]string) {
+	fieldErrors = map[string]string{}
+
+	portForwarded.Enabled = r.PostFormValue("enabled") == "1"
+	portForwarded.Name = r.PostFormValue("name")
+	portForwarded.Protocol = r.PostFormValue("protocol")
+	portForwarded.LanIp = r.PostFormValue("destinationip")
+	portForwarded.LanMac = macFromIP(portForwarded.LanIp)
+
+	if portForwarded.Name == "" {
+		fieldErrors["name"] = "Name is required"
+	} else if len(portForwarded.Name) > maxNameLength {
+		fieldErrors["name"] = fmt.Sprintf("Name must be at most %d characters", maxNameLength)
+	}
+
+	if portForwarded.LanMac == "" {
+		fieldErrors["destinationip"] = "Invalid IPv4 address"
+	}
+
+	var err error
+	portForwarded.WanPortStart, portForwarded.WanPortEnd, err = parsePortRange(r.PostFormValue("wanport"))
+	if err != nil {
+		fieldErrors["wanport"] = "Invalid port or port range"
+	}
+
+	portForwarded.LanPortStart, portForwarded.LanPortEnd, err = parsePortRange(r.PostFormValue("lanport"))
+	if err != nil {
+		fieldErrors["lanport"] = "Invalid port or port range"
+	}
+
+	if _, ok := fieldErrors["wanport"]; !ok {
+		for i, other := range gateway.portForwarding {
+			if i+1 != index && strings.EqualFold(other.Protocol, portForwarded.Protocol) &&
+				portForwarded.WanPortStart <= other.WanPortEnd && other.WanPortStart <= portForwarded.WanPortEnd {
+				fieldErrors["wanport"] = fmt.Sprintf("Port already used by %s", other.Name)
+				break
+			}
+		}
+	}
+	return
+}
+
+var portRangeRegex = regexp.MustCompile(`^(\d+)(?:-(\d+))?$`)
+
+func parsePortRange(s string) (start int, end int, err error) {
+	matches := portRangeRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, 0, fmt.Errorf("invalid port %q", s)
+	}
+	start, _ = strconv.Atoi(matches[1])
+	end = start
+	if matches[2] != "" {
+		end, _ = strconv.Atoi(matches[2])
+	}
+	if start < 1 || end > 65535 || end < start {
+		return 0, 0, fmt.Errorf("invalid port %q", s)
+	}
+	return
+}
+
+func formatPortRange(start int, end int) string {
+	if end == 0 || end == start {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
+
+// macFromIP gives every lan ip a stable, locally administered mac address
+func macFromIP(ip string) string {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		return ""
+	}
+	return fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", parsed[0], parsed[1], parsed[2], parsed[3])
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,109 @@
+package technicolortest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"terraform-provider-technicolor/technicolor"
+)
+
+// Replay is an http.RoundTripper answering with the exchanges saved by a
+// technicolor.Recorder, to run the client against the pages captured from
+// a router. A request gets the recorded responses to the same method, path
+// and table action in order, the last one is repeated. A request that was
+// never recorded is answered with 404.
+type Replay struct {
+	mu        sync.Mutex
+	exchanges map[string][]technicolor.Exchange
+	served    map[string]int
+}
+
+// NewReplay loads the exchanges recorded in dir
+func NewReplay(dir string) (*Replay, error) {
+	err, exchanges := technicolor.ReadExchanges(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("no exchange recorded in %s", dir)
+	}
+
+	replay := &Replay{
+		exchanges: map[string][]technicolor.Exchange{},
+		served:    map[string]int{},
+	}
+
+	for _, exchange := range exchanges {
+		key := replayKey(exchange.Method, exchange.Path, exchange.Query, exchange.Form["action"])
+		replay.exchanges[key] = append(replay.exchanges[key], exchange)
+	}
+
+	return replay, nil
+}
+
+func (replay *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	var action string
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		form, _ := url.ParseQuery(string(body))
+		action = form.Get("action")
+	}
+
+	key := replayKey(req.Method, path.Clean("/"+req.URL.Path), req.URL.RawQuery, action)
+
+	replay.mu.Lock()
+	exchanges := replay.exchanges[key]
+	served := replay.served[key]
+	if served < len(exchanges)-1 {
+		replay.served[key]++
+	}
+	replay.mu.Unlock()
+
+	if len(exchanges) == 0 {
+		return replayResponse(req, http.StatusNotFound, "text/plain", []byte("no exchange recorded for "+key)), nil
+	}
+
+	exchange := exchanges[served]
+	return replayResponse(req, exchange.StatusCode, exchange.ContentType, exchange.Body), nil
+}
+
+func replayKey(method string, path string, query string, action string) string {
+	key := method + " " + path
+	if query != "" {
+		key += "?" + query
+	}
+	if action != "" {
+		key += " " + action
+	}
+	return key
+}
+
+func replayResponse(req *http.Request, statusCode int, contentType string, body []byte) *http.Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}